@@ -10,6 +10,7 @@ import (
 	"sort"
 	"strings"
 
+	humanize "github.com/dustin/go-humanize"
 	"github.com/zyedidia/micro/v2/internal/buffer"
 	"github.com/zyedidia/micro/v2/internal/config"
 )
@@ -28,6 +29,29 @@ func shouldContinue() bool {
 	return len(text) == 0 || strings.ToLower(text)[0] == 'y'
 }
 
+// ClearState removes all the state persisted in the user's configuration directory
+func ClearState() {
+	entries := config.ShowState()
+	if len(entries) == 0 {
+		fmt.Println("No persisted state")
+		return
+	}
+
+	fmt.Println("The following state will be removed:")
+	for _, e := range entries {
+		fmt.Printf("%s (%s)\n", e.Path, humanize.Bytes(uint64(e.Size)))
+	}
+
+	if !shouldContinue() {
+		fmt.Println("Stopping early")
+		return
+	}
+
+	if err := config.ClearState(); err != nil {
+		fmt.Println("Error clearing state: " + err.Error())
+	}
+}
+
 // CleanConfig performs cleanup in the user's configuration directory
 func CleanConfig() {
 	fmt.Println("Cleaning your configuration directory at", config.ConfigDir)
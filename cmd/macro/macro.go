@@ -23,10 +23,12 @@ import (
 	"github.com/zyedidia/micro/v2/internal/buffer"
 	"github.com/zyedidia/micro/v2/internal/clipboard"
 	"github.com/zyedidia/micro/v2/internal/config"
+	ulua "github.com/zyedidia/micro/v2/internal/lua"
 	"github.com/zyedidia/micro/v2/internal/screen"
 	"github.com/zyedidia/micro/v2/internal/shell"
 	"github.com/zyedidia/micro/v2/internal/util"
 	"github.com/zyedidia/tcell/v2"
+	luar "layeh.com/gopher-luar"
 )
 
 var (
@@ -38,6 +40,9 @@ var (
 	flagProfile   = flag.Bool("profile", false, "Enable CPU profiling (writes profile info to ./micro.prof)")
 	flagPlugin    = flag.String("plugin", "", "Plugin command")
 	flagClean     = flag.Bool("clean", false, "Clean configuration directory")
+	flagClear     = flag.Bool("clear-state", false, "Clear persisted state")
+	flagDiff      = flag.Bool("diff", false, "Compare two files side by side")
+	flagNoSession = flag.Bool("no-session", false, "Do not restore the previous session")
 	optionFlags   map[string]*string
 
 	sigterm chan os.Signal
@@ -51,8 +56,17 @@ func InitFlags() {
 		fmt.Println("Usage: macro [OPTIONS] [FILE]...")
 		fmt.Println("-clean")
 		fmt.Println("    \tCleans the configuration directory")
+		fmt.Println("-clear-state")
+		fmt.Println("    \tClears the persisted state (cursor positions, undo and command history,")
+		fmt.Println("    \topen and recent files, view toggles and bookmarks)")
+		fmt.Println("-diff FILE FILE")
+		fmt.Println("    \tOpen two files side by side and show their differences")
+		fmt.Println("-no-session")
+		fmt.Println("    \tDo not reopen the files of the previous session when no file is given")
 		fmt.Println("-config-dir dir")
 		fmt.Println("    \tSpecify a custom location for the configuration directory")
+		fmt.Println("-")
+		fmt.Println("    \tRead a buffer from stdin, which is also done when input is piped and no file is given")
 		fmt.Println("[FILE]:LINE:COL (if the `parsecursor` option is enabled)")
 		fmt.Println("+LINE:COL")
 		fmt.Println("    \tSpecify a line and column to start the cursor at when opening a buffer")
@@ -141,6 +155,21 @@ func DoPluginFlags() {
 	}
 }
 
+// checkDiffArgs verifies that the arguments given to -diff are two existing files
+func checkDiffArgs(args []string) error {
+	if len(args) != 2 {
+		return errors.New("Error: -diff expects exactly two files")
+	}
+	for _, a := range args {
+		if info, err := os.Stat(a); err != nil {
+			return errors.New("Error: " + err.Error())
+		} else if info.IsDir() {
+			return errors.New("Error: " + a + " is a directory")
+		}
+	}
+	return nil
+}
+
 // LoadInput determines which files should be loaded into buffers
 // based on the input stored in flag.Args()
 func LoadInput(args []string) []*buffer.Buffer {
@@ -156,8 +185,6 @@ func LoadInput(args []string) []*buffer.Buffer {
 	// should be opened
 
 	var filename string
-	var input []byte
-	var err error
 	buffers := make([]*buffer.Buffer, 0, len(args))
 
 	btype := buffer.BTDefault
@@ -198,6 +225,13 @@ func LoadInput(args []string) []*buffer.Buffer {
 		// Option 1
 		// We go through each file and load it
 		for i := 0; i < len(files); i++ {
+			if files[i] == "-" {
+				// a single dash reads stdin, as for Option 2
+				buf := buffer.NewBufferFromStringAtLoc(string(readStdin()), filename, btype, flagStartPos)
+				buf.SetName("stdin")
+				buffers = append(buffers, buf)
+				continue
+			}
 			buf, err := buffer.NewBufferFromFileAtLoc(files[i], btype, flagStartPos)
 			if err != nil {
 				screen.TermMessage(err)
@@ -210,17 +244,75 @@ func LoadInput(args []string) []*buffer.Buffer {
 		// Option 2
 		// The input is not a terminal, so something is being piped in
 		// and we should read from stdin
-		input, err = ioutil.ReadAll(os.Stdin)
-		if err != nil {
-			screen.TermMessage("Error reading from stdin: ", err)
-			input = []byte{}
-		}
-		buffers = append(buffers, buffer.NewBufferFromStringAtLoc(string(input), filename, btype, flagStartPos))
+		buf := buffer.NewBufferFromStringAtLoc(string(readStdin()), filename, btype, flagStartPos)
+		buf.SetName("stdin")
+		buffers = append(buffers, buf)
+	} else if session := loadSession(btype); len(session) > 0 {
+		// Option 3, reopen the files of the previous session
+		buffers = session
 	} else {
-		// Option 3, just open an empty buffer
-		buffers = append(buffers, buffer.NewBufferFromStringAtLoc(string(input), filename, btype, flagStartPos))
+		// Option 4, just open an empty buffer
+		buffers = append(buffers, buffer.NewBufferFromStringAtLoc("", filename, btype, flagStartPos))
+	}
+
+	return buffers
+}
+
+// readStdin reads all of the standard input, or reports the error and returns
+// nothing
+func readStdin() []byte {
+	input, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		screen.TermMessage("Error reading from stdin: ", err)
+		return []byte{}
+	}
+	return input
+}
+
+// sessionBuffer is the buffer which was being edited when the restored
+// session was saved
+var sessionBuffer *buffer.Buffer
+
+// loadSession opens the files of the previous session, unless disabled.
+// Files which do not exist anymore are skipped
+func loadSession(btype buffer.BufType) []*buffer.Buffer {
+	if *flagNoSession || !config.GetGlobalOption("savesession").(bool) {
+		return nil
+	}
+	session, err := config.LoadSession()
+	if err != nil {
+		return nil
 	}
 
+	savecursor := config.GetGlobalOption("savecursor").(bool)
+	saveundo := config.GetGlobalOption("saveundo").(bool)
+	var buffers []*buffer.Buffer
+	for i, sb := range session.Buffers {
+		if _, err := os.Stat(sb.Path); err != nil {
+			continue
+		}
+		loc := buffer.Loc{X: sb.X, Y: sb.Y}
+		start := loc
+		if savecursor || saveundo {
+			// let the buffer restore its serialized cursor and undo history
+			start = buffer.Loc{X: -1, Y: -1}
+		}
+		buf, err := buffer.NewBufferFromFileAtLoc(sb.Path, btype, start)
+		if err != nil {
+			continue
+		}
+		if saveundo && !savecursor {
+			// only the undo history was restored, the cursor is the one of
+			// the session
+			c := buf.GetActiveCursor()
+			c.GotoLoc(loc)
+			c.Relocate()
+		}
+		if i == session.Current {
+			sessionBuffer = buf
+		}
+		buffers = append(buffers, buf)
+	}
 	return buffers
 }
 
@@ -265,6 +357,8 @@ func main() {
 	if err != nil {
 		screen.TermMessage(err)
 	}
+	// the view preferences are optional
+	config.LoadViewPrefs()
 
 	// flag options
 	for k, v := range optionFlags {
@@ -281,6 +375,20 @@ func main() {
 
 	DoPluginFlags()
 
+	if *flagClear {
+		ClearState()
+		os.Exit(0)
+	}
+
+	if *flagDiff {
+		if err := checkDiffArgs(flag.Args()); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		config.GlobalSettings["multiopen"] = "vsplit"
+		config.VolatileSettings["multiopen"] = true
+	}
+
 	err = screen.Init()
 	if err != nil {
 		fmt.Println(err)
@@ -328,6 +436,17 @@ func main() {
 
 	action.InitGlobals()
 	buffer.SetMessager(action.InfoBar)
+	buffer.OnContentChanged(func(b *buffer.Buffer) {
+		// only the buffers of files, not the prompt, log, scratch or
+		// outline buffers
+		if b.Type.Kind != buffer.BTDefault.Kind {
+			return
+		}
+		// not a TermMessage, which would interrupt typing
+		if err := config.RunPluginFn("onBufferModified", luar.New(ulua.L, b)); err != nil {
+			action.InfoBar.Error(err)
+		}
+	})
 	args := flag.Args()
 	b := LoadInput(args)
 
@@ -337,7 +456,14 @@ func main() {
 		runtime.Goexit()
 	}
 
+	if *flagDiff && len(b) == 2 {
+		buffer.DiffBuffers(b[0], b[1])
+	}
+
 	action.InitTabs(b)
+	if sessionBuffer != nil {
+		action.ActivateBuffer(sessionBuffer)
+	}
 
 	err = config.RunPluginFn("init")
 	if err != nil {
@@ -430,6 +556,8 @@ func DoEvent() {
 		}
 	case f := <-timerChan:
 		f()
+	case b := <-buffer.ContentChanged:
+		buffer.NotifyContentChanged(b)
 	case <-sighup:
 		for _, b := range buffer.OpenBuffers {
 			if !b.Modified() {
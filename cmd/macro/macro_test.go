@@ -0,0 +1,23 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckDiffArgs(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.txt")
+	b := filepath.Join(dir, "b.txt")
+	os.WriteFile(a, []byte("foo\n"), 0644)
+	os.WriteFile(b, []byte("bar\n"), 0644)
+
+	assert.Nil(t, checkDiffArgs([]string{a, b}))
+	assert.NotNil(t, checkDiffArgs([]string{a}))
+	assert.NotNil(t, checkDiffArgs([]string{a, b, a}))
+	assert.NotNil(t, checkDiffArgs([]string{a, filepath.Join(dir, "c.txt")}))
+	assert.NotNil(t, checkDiffArgs([]string{a, dir}))
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io/fs"
 	"os"
+	"path/filepath"
 	"regexp"
 	"runtime"
 	"strings"
@@ -403,6 +404,68 @@ func (h *BufPane) SelectLine() bool {
 	return true
 }
 
+// SelectWord selects the word under the cursor, or the character under the
+// cursor if it is not part of a word
+func (h *BufPane) SelectWord() bool {
+	h.Cursor.SelectWord()
+	h.Relocate()
+	return true
+}
+
+// SelectParagraph selects the paragraph the cursor is on
+func (h *BufPane) SelectParagraph() bool {
+	h.Cursor.SelectParagraph()
+	h.Relocate()
+	return true
+}
+
+// ExpandSelection grows the selection to the word, the line, the enclosing
+// brackets and finally the whole buffer, one step at a time
+func (h *BufPane) ExpandSelection() bool {
+	sel := h.Cursor.CurSelection
+	if !h.Cursor.HasSelection() {
+		sel = [2]buffer.Loc{h.Cursor.Loc, h.Cursor.Loc}
+	}
+	if n := len(h.expandStack); n == 0 || h.expandStack[n-1] != sel {
+		h.expandStack = [][2]buffer.Loc{sel}
+	}
+
+	next := h.Buf.ExpandSelection(sel)
+	if next == sel {
+		return false
+	}
+	h.expandStack = append(h.expandStack, next)
+	h.Cursor.SetSelectionStart(next[0])
+	h.Cursor.SetSelectionEnd(next[1])
+	h.Cursor.OrigSelection = h.Cursor.CurSelection
+	h.Cursor.Loc = next[1]
+	h.Relocate()
+	return true
+}
+
+// ShrinkSelection goes back to the selection before the last ExpandSelection
+func (h *BufPane) ShrinkSelection() bool {
+	n := len(h.expandStack)
+	if n < 2 || h.expandStack[n-1] != h.Cursor.CurSelection {
+		h.expandStack = nil
+		return false
+	}
+
+	h.expandStack = h.expandStack[:n-1]
+	prev := h.expandStack[n-2]
+	if prev[0] == prev[1] {
+		h.Cursor.ResetSelection()
+		h.Cursor.GotoLoc(prev[0])
+	} else {
+		h.Cursor.SetSelectionStart(prev[0])
+		h.Cursor.SetSelectionEnd(prev[1])
+		h.Cursor.OrigSelection = h.Cursor.CurSelection
+		h.Cursor.Loc = prev[1]
+	}
+	h.Relocate()
+	return true
+}
+
 // SelectToStartOfText selects to the start of the text on the current line
 func (h *BufPane) SelectToStartOfText() bool {
 	if !h.Cursor.HasSelection() {
@@ -595,6 +658,15 @@ func (h *BufPane) InsertNewline() bool {
 	if h.Buf.Settings["autoindent"].(bool) {
 		if cx < len(ws) {
 			ws = ws[0:cx]
+		} else if h.Buf.Settings["smartindent"].(bool) && h.Buf.OpensIndent(h.Buf.LineBytes(h.Cursor.Y-1)) {
+			// between brackets, the closing one goes on its own line
+			// with the indentation of the opening one
+			if buffer.ClosesIndent(h.Buf.LineBytes(h.Cursor.Y-1), h.Buf.LineBytes(h.Cursor.Y)) {
+				loc := h.Cursor.Loc
+				h.Buf.Insert(loc, "\n"+string(ws))
+				h.Cursor.GotoLoc(loc)
+			}
+			ws = append(ws, h.Buf.IndentString(util.IntOpt(h.Buf.Settings["tabsize"]))...)
 		}
 		h.Buf.Insert(h.Cursor.Loc, string(ws))
 		// for i := 0; i < len(ws); i++ {
@@ -612,6 +684,24 @@ func (h *BufPane) InsertNewline() bool {
 	return true
 }
 
+// InsertLineBelow inserts a new line below the current one and moves the
+// cursor to it, keeping the indentation of the current line
+func (h *BufPane) InsertLineBelow() bool {
+	h.Cursor.Deselect(false)
+	h.Cursor.GotoLoc(h.Buf.OpenLine(h.Cursor.Y, false))
+	h.Relocate()
+	return true
+}
+
+// InsertLineAbove inserts a new line above the current one and moves the
+// cursor to it, keeping the indentation of the current line
+func (h *BufPane) InsertLineAbove() bool {
+	h.Cursor.Deselect(true)
+	h.Cursor.GotoLoc(h.Buf.OpenLine(h.Cursor.Y, true))
+	h.Relocate()
+	return true
+}
+
 // Backspace deletes the previous character
 func (h *BufPane) Backspace() bool {
 	if h.Cursor.HasSelection() {
@@ -823,19 +913,104 @@ func (h *BufPane) InsertTab() bool {
 	return true
 }
 
-// SaveAll saves all open buffers
+// SaveAll saves all modified buffers, and reports those which could not be
+// saved
 func (h *BufPane) SaveAll() bool {
-	for _, b := range buffer.OpenBuffers {
-		b.Save()
+	saved, total, errs := saveModifiedBuffers()
+	switch {
+	case len(errs) > 0:
+		InfoBar.Error(fmt.Sprintf("Saved %d of %s: ", saved, countBuffers(total)), joinErrors(errs))
+	case total == 0:
+		InfoBar.Message("No modified buffers to save")
+	default:
+		InfoBar.Message("Saved " + countBuffers(saved))
 	}
 	return true
 }
 
+func countBuffers(n int) string {
+	if n == 1 {
+		return "1 buffer"
+	}
+	return fmt.Sprintf("%d buffers", n)
+}
+
+// saveModifiedBuffers saves every modified buffer like the Save action does,
+// formatting it first if formatonsave is on. Files which changed on disk are
+// not overwritten. It returns how many buffers were saved out of how many
+// were modified, and the problems met along the way
+func saveModifiedBuffers() (int, int, []error) {
+	saved, total := 0, 0
+	var errs []error
+	for _, b := range buffer.OpenBuffers {
+		// buffers opened in several panes are only saved once, since they
+		// are not modified anymore afterwards
+		if !b.Modified() {
+			continue
+		}
+		total++
+		if b.Path == "" {
+			errs = append(errs, errors.New(b.GetName()+" has no file name"))
+			continue
+		} else if b.Type.Readonly {
+			errs = append(errs, errors.New(b.GetName()+" is read-only"))
+			continue
+		} else if b.ExternallyModified() {
+			errs = append(errs, errors.New(b.GetName()+" changed on disk"))
+			continue
+		}
+
+		// as with SaveCB, the buffer is saved even if it can't be formatted
+		if bp := bufPaneOf(b); bp != nil {
+			if err := bp.formatBeforeSave(); err != nil {
+				errs = append(errs, errors.New(b.GetName()+": could not format: "+err.Error()))
+			}
+		}
+		if err := b.Save(); err != nil {
+			errs = append(errs, errors.New(b.GetName()+": "+err.Error()))
+		} else {
+			saved++
+		}
+	}
+	return saved, total, errs
+}
+
+// bufPaneOf returns a pane showing the given buffer, if any
+func bufPaneOf(b *buffer.Buffer) *BufPane {
+	for _, t := range Tabs.List {
+		for _, p := range t.Panes {
+			if bp, ok := p.(*BufPane); ok && bp.Buf == b {
+				return bp
+			}
+		}
+	}
+	return nil
+}
+
+// joinErrors returns the messages of the given errors separated by commas
+func joinErrors(errs []error) string {
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, ", ")
+}
+
 // SaveCB performs a save and does a callback at the very end (after all prompts have been resolved)
 func (h *BufPane) SaveCB(action string, callback func()) bool {
 	// If this is an empty buffer, ask for a filename
 	if h.Buf.Path == "" {
 		h.SaveAsCB(action, callback)
+	} else if h.Buf.ExternallyModified() {
+		// the file changed on disk since it was opened or last saved
+		InfoBar.YNPrompt("The file on disk has changed. Overwrite it? (y,n,esc)", func(yes, canceled bool) {
+			if yes && !canceled {
+				noPrompt := h.saveBufToFile(h.Buf.Path, action, callback)
+				if noPrompt {
+					h.completeAction(action)
+				}
+			}
+		})
 	} else {
 		noPrompt := h.saveBufToFile(h.Buf.Path, action, callback)
 		if noPrompt {
@@ -866,28 +1041,55 @@ func (h *BufPane) SaveAsCB(action string, callback func()) bool {
 				return
 			}
 			filename := strings.Join(args, " ")
-			fileinfo, err := os.Stat(filename)
-			if err != nil {
-				if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrPermission) {
-					noPrompt := h.saveBufToFile(filename, action, callback)
-					if noPrompt {
-						h.completeAction(action)
-						return
+			save := func() {
+				fileinfo, err := os.Stat(filename)
+				if err != nil {
+					if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrPermission) {
+						noPrompt := h.saveBufToFile(filename, action, callback)
+						if noPrompt {
+							h.completeAction(action)
+							return
+						}
 					}
+				} else {
+					InfoBar.YNPrompt(
+						fmt.Sprintf("The file %s already exists in the directory, would you like to overwrite? Y/n", fileinfo.Name()),
+						func(yes, canceled bool) {
+							if yes && !canceled {
+								noPrompt := h.saveBufToFile(filename, action, callback)
+								if noPrompt {
+									h.completeAction(action)
+								}
+							}
+						},
+					)
 				}
-			} else {
+			}
+
+			// offer to create the missing parent directories, unless
+			// mkparents does it anyway
+			absFilename, err := util.ReplaceHome(filename)
+			if err != nil {
+				InfoBar.Error(err)
+				return
+			}
+			dir := filepath.Dir(absFilename)
+			if _, err := os.Stat(dir); errors.Is(err, fs.ErrNotExist) && !h.Buf.Settings["mkparents"].(bool) {
 				InfoBar.YNPrompt(
-					fmt.Sprintf("The file %s already exists in the directory, would you like to overwrite? Y/n", fileinfo.Name()),
+					fmt.Sprintf("The directory %s does not exist, would you like to create it? (y,n,esc)", dir),
 					func(yes, canceled bool) {
 						if yes && !canceled {
-							noPrompt := h.saveBufToFile(filename, action, callback)
-							if noPrompt {
-								h.completeAction(action)
+							if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+								InfoBar.Error(err)
+								return
 							}
+							save()
 						}
 					},
 				)
+				return
 			}
+			save()
 		}
 	})
 	return false
@@ -898,10 +1100,49 @@ func (h *BufPane) SaveAs() bool {
 	return h.SaveAsCB("SaveAs", nil)
 }
 
+// Reopen reloads the file from disk, replacing the contents of the buffer.
+// If the buffer is modified, it asks whether to save it first
+func (h *BufPane) Reopen() bool {
+	reopen := func() {
+		if err := h.Buf.ReOpen(); err != nil {
+			InfoBar.Error(err)
+			return
+		}
+		h.Relocate()
+		InfoBar.Message("Reopened " + h.Buf.GetName())
+	}
+
+	if h.Buf.Modified() {
+		InfoBar.YNPrompt("Save file before reopen? (y,n,esc)", func(yes, canceled bool) {
+			if !canceled && yes {
+				// reopen only once the save is done, after its own prompts
+				h.SaveCB("Reopen", reopen)
+			} else if !canceled {
+				reopen()
+			}
+		})
+	} else {
+		reopen()
+	}
+	return true
+}
+
 // This function saves the buffer to `filename` and changes the buffer's path and name
 // to `filename` if the save is successful
 // The callback is only called if the save was successful
 func (h *BufPane) saveBufToFile(filename string, action string, callback func()) bool {
+	// formatting waits until the save is certain, after all the prompts
+	if err := h.formatBeforeSave(); err != nil {
+		// the buffer is saved anyway, and the error replaces the save message
+		saved := callback
+		callback = func() {
+			InfoBar.Error("Could not format the buffer: ", err)
+			if saved != nil {
+				saved()
+			}
+		}
+	}
+
 	err := h.Buf.SaveAs(filename)
 	if err != nil {
 		if errors.Is(err, fs.ErrPermission) {
@@ -938,7 +1179,11 @@ func (h *BufPane) saveBufToFile(filename string, action string, callback func())
 	} else {
 		h.Buf.Path = filename
 		h.Buf.SetName(filename)
-		InfoBar.Message("Saved " + filename)
+		if h.Buf.SaveBackupErr != nil {
+			InfoBar.Message("Saved " + filename + ", but could not write its backup: " + h.Buf.SaveBackupErr.Error())
+		} else {
+			InfoBar.Message("Saved " + filename)
+		}
 		if callback != nil {
 			callback()
 		}
@@ -984,42 +1229,52 @@ func (h *BufPane) Search(str string, useRegex bool, searchDown bool) error {
 
 func (h *BufPane) find(useRegex bool) bool {
 	h.searchOrig = h.Cursor.Loc
-	prompt := "Find: "
-	if useRegex {
-		prompt = "Find (regex): "
-	}
+	h.searchRegex = useRegex
 	var eventCallback func(resp string)
 	if h.Buf.Settings["incsearch"].(bool) {
 		eventCallback = func(resp string) {
-			match, found, _ := h.Buf.FindNext(resp, h.Buf.Start(), h.Buf.End(), h.searchOrig, true, useRegex)
+			match, found, err := h.Buf.FindNext(resp, h.Buf.Start(), h.Buf.End(), h.searchOrig, true, h.searchRegex)
+			// show an invalid regex by displaying the prompt as an error
+			InfoBar.HasError = err != nil
+			// the matches shown while typing are not recorded in the
+			// location history, only the final one is
+			traversingHistory = true
+			defer func() { traversingHistory = false }()
 			if found {
 				h.Cursor.SetSelectionStart(match[0])
 				h.Cursor.SetSelectionEnd(match[1])
 				h.Cursor.OrigSelection[0] = h.Cursor.CurSelection[0]
 				h.Cursor.OrigSelection[1] = h.Cursor.CurSelection[1]
 				h.GotoLoc(match[1])
+				InfoBar.Msg = h.findPrompt(h.matchCounter(resp, h.searchRegex, match[0]))
 			} else {
 				h.GotoLoc(h.searchOrig)
 				h.Cursor.ResetSelection()
+				InfoBar.Msg = h.findPrompt("")
 			}
 		}
 	}
 	findCallback := func(resp string, canceled bool) {
 		// Finished callback
+		InfoBar.HasError = false
 		if !canceled {
-			match, found, err := h.Buf.FindNext(resp, h.Buf.Start(), h.Buf.End(), h.searchOrig, true, useRegex)
+			match, found, err := h.Buf.FindNext(resp, h.Buf.Start(), h.Buf.End(), h.searchOrig, true, h.searchRegex)
 			if err != nil {
 				InfoBar.Error(err)
 			}
 			if found {
+				// jump from where the search started, so that it is the
+				// position recorded in the location history
+				h.Cursor.GotoLoc(h.searchOrig)
 				h.Cursor.SetSelectionStart(match[0])
 				h.Cursor.SetSelectionEnd(match[1])
 				h.Cursor.OrigSelection[0] = h.Cursor.CurSelection[0]
 				h.Cursor.OrigSelection[1] = h.Cursor.CurSelection[1]
 				h.GotoLoc(h.Cursor.CurSelection[1])
 				h.Buf.LastSearch = resp
-				h.Buf.LastSearchRegex = useRegex
+				h.Buf.LastSearchRegex = h.searchRegex
 				h.Buf.HighlightSearch = h.Buf.Settings["hlsearch"].(bool)
+				h.showMatch(match, match[0].LessThan(h.searchOrig))
 			} else {
 				h.Cursor.ResetSelection()
 				InfoBar.Message("No matches found")
@@ -1032,16 +1287,50 @@ func (h *BufPane) find(useRegex bool) bool {
 	if useRegex && pattern != "" {
 		pattern = regexp.QuoteMeta(pattern)
 	}
+	InfoBar.Prompt(h.findPrompt(""), pattern, "Find", eventCallback, findCallback)
 	if eventCallback != nil && pattern != "" {
 		eventCallback(pattern)
 	}
-	InfoBar.Prompt(prompt, pattern, "Find", eventCallback, findCallback)
 	if pattern != "" {
 		InfoBar.SelectAll()
 	}
 	return true
 }
 
+// findPrompt returns the prompt of the find prompt, indicating whether the
+// search is a regex search, followed by the given match counter
+func (h *BufPane) findPrompt(counter string) string {
+	prompt := "Find"
+	if h.searchRegex {
+		prompt += " (regex)"
+	}
+	if counter != "" {
+		prompt += " " + counter
+	}
+	return prompt + ": "
+}
+
+// matchCounter returns the position of the match starting at the given
+// location among all the matches of a search, formatted as "(3/12)"
+func (h *BufPane) matchCounter(s string, useRegex bool, start buffer.Loc) string {
+	i, n, err := h.Buf.MatchIndex(s, useRegex, start)
+	if err != nil || n == 0 {
+		return ""
+	}
+	return fmt.Sprintf("(%d/%d)", i, n)
+}
+
+// showMatch displays the position of a match of the last search, and
+// whether the search wrapped around the buffer to find it
+func (h *BufPane) showMatch(match [2]buffer.Loc, wrapped bool) {
+	counter := h.matchCounter(h.Buf.LastSearch, h.Buf.LastSearchRegex, match[0])
+	if wrapped {
+		InfoBar.Message("Search wrapped, match " + counter)
+	} else {
+		InfoBar.Message("Match " + counter)
+	}
+}
+
 // ToggleHighlightSearch toggles highlighting all instances of the last used search term
 func (h *BufPane) ToggleHighlightSearch() bool {
 	h.Buf.HighlightSearch = !h.Buf.HighlightSearch
@@ -1074,6 +1363,7 @@ func (h *BufPane) FindNext() bool {
 		h.Cursor.OrigSelection[0] = h.Cursor.CurSelection[0]
 		h.Cursor.OrigSelection[1] = h.Cursor.CurSelection[1]
 		h.GotoLoc(h.Cursor.CurSelection[1])
+		h.showMatch(match, match[0].LessThan(searchLoc))
 	} else {
 		h.Cursor.ResetSelection()
 	}
@@ -1100,36 +1390,92 @@ func (h *BufPane) FindPrevious() bool {
 		h.Cursor.OrigSelection[0] = h.Cursor.CurSelection[0]
 		h.Cursor.OrigSelection[1] = h.Cursor.CurSelection[1]
 		h.GotoLoc(h.Cursor.CurSelection[1])
+		h.showMatch(match, searchLoc.LessThan(match[1]))
 	} else {
 		h.Cursor.ResetSelection()
 	}
 	return true
 }
 
-// DiffNext searches forward until the beginning of the next block of diffs
+// DiffNext searches forward until the beginning of the next block of diffs,
+// wrapping around the buffer
 func (h *BufPane) DiffNext() bool {
-	cur := h.Cursor.Loc.Y
-	dl, err := h.Buf.FindNextDiffLine(cur, true)
-	if err != nil {
+	return h.diffJump(true)
+}
+
+// DiffPrevious searches forward until the end of the previous block of diffs,
+// wrapping around the buffer
+func (h *BufPane) DiffPrevious() bool {
+	return h.diffJump(false)
+}
+
+func (h *BufPane) diffJump(forward bool) bool {
+	dl, ok := h.Buf.NextDiffLine(h.Cursor.Loc.Y, forward)
+	if !ok {
+		InfoBar.Message("No changes")
 		return false
 	}
-	h.GotoLoc(buffer.Loc{0, dl})
+	h.GotoLoc(buffer.Loc{X: 0, Y: dl})
 	return true
 }
 
-// DiffPrevious searches forward until the end of the previous block of diffs
-func (h *BufPane) DiffPrevious() bool {
-	cur := h.Cursor.Loc.Y
-	dl, err := h.Buf.FindNextDiffLine(cur, false)
-	if err != nil {
+// ToggleBookmark bookmarks the current line, or removes its bookmark
+func (h *BufPane) ToggleBookmark() bool {
+	if h.Buf.ToggleBookmark(h.Cursor.Y) {
+		InfoBar.Message(fmt.Sprintf("Bookmarked line %d", h.Cursor.Y+1))
+	} else {
+		InfoBar.Message(fmt.Sprintf("Removed bookmark of line %d", h.Cursor.Y+1))
+	}
+	return true
+}
+
+// NextBookmark moves the cursor to the next bookmarked line, wrapping around
+// the buffer
+func (h *BufPane) NextBookmark() bool {
+	return h.bookmarkJump(true)
+}
+
+// PreviousBookmark moves the cursor to the previous bookmarked line,
+// wrapping around the buffer
+func (h *BufPane) PreviousBookmark() bool {
+	return h.bookmarkJump(false)
+}
+
+// NextGutterMessage moves the cursor to the next line with a gutter message
+// (such as a linter error), wrapping around the buffer
+func (h *BufPane) NextGutterMessage() bool {
+	return h.gutterMessageJump(true)
+}
+
+// PreviousGutterMessage moves the cursor to the previous line with a gutter
+// message, wrapping around the buffer
+func (h *BufPane) PreviousGutterMessage() bool {
+	return h.gutterMessageJump(false)
+}
+
+func (h *BufPane) gutterMessageJump(forward bool) bool {
+	y, ok := h.Buf.NextMessageLine(h.Cursor.Y, forward)
+	if !ok {
+		InfoBar.Message("No messages")
+		return false
+	}
+	h.GotoLoc(buffer.Loc{X: 0, Y: y})
+	return true
+}
+
+func (h *BufPane) bookmarkJump(forward bool) bool {
+	y, ok := h.Buf.NextBookmark(h.Cursor.Y, forward)
+	if !ok {
+		InfoBar.Message("No bookmarks")
 		return false
 	}
-	h.GotoLoc(buffer.Loc{0, dl})
+	h.GotoLoc(buffer.Loc{X: 0, Y: y})
 	return true
 }
 
 // Undo undoes the last action
 func (h *BufPane) Undo() bool {
+	h.snippet = nil
 	h.Buf.Undo()
 	InfoBar.Message("Undid action")
 	h.Relocate()
@@ -1138,6 +1484,7 @@ func (h *BufPane) Undo() bool {
 
 // Redo redoes the last action
 func (h *BufPane) Redo() bool {
+	h.snippet = nil
 	h.Buf.Redo()
 	InfoBar.Message("Redid action")
 	h.Relocate()
@@ -1218,7 +1565,7 @@ func (h *BufPane) DuplicateLine() bool {
 	var infoMessage = "Duplicated line"
 	if h.Cursor.HasSelection() {
 		infoMessage = "Duplicated selection"
-		h.Buf.Insert(h.Cursor.CurSelection[1], string(h.Cursor.GetSelection()))
+		h.Cursor.DuplicateSelection()
 	} else {
 		h.Cursor.End()
 		h.Buf.Insert(h.Cursor.Loc, "\n"+string(h.Buf.LineBytes(h.Cursor.Y)))
@@ -1392,9 +1739,34 @@ func (h *BufPane) JumpToMatchingBrace() bool {
 			}
 		}
 	}
+	InfoBar.Message("No matching brace")
 	return false
 }
 
+// JumpToBlockStart moves the cursor to the previous line with less
+// indentation, which starts the current indentation block
+func (h *BufPane) JumpToBlockStart() bool {
+	return h.jumpToBlockBoundary(false)
+}
+
+// JumpToBlockEnd moves the cursor to the next line with less
+// indentation, which ends the current indentation block
+func (h *BufPane) JumpToBlockEnd() bool {
+	return h.jumpToBlockBoundary(true)
+}
+
+func (h *BufPane) jumpToBlockBoundary(forward bool) bool {
+	y := h.Buf.FindBlockBoundary(h.Cursor.Y, forward)
+	if y == h.Cursor.Y {
+		return false
+	}
+	h.Cursor.Deselect(true)
+	h.GotoLoc(buffer.Loc{X: 0, Y: y})
+	h.Cursor.StartOfText()
+	h.Relocate()
+	return true
+}
+
 // SelectAll selects the entire buffer
 func (h *BufPane) SelectAll() bool {
 	h.Cursor.SetSelectionStart(h.Buf.Start())
@@ -1418,11 +1790,7 @@ func (h *BufPane) OpenFile() bool {
 
 // JumpLine asks the user to enter a line number to jump to
 func (h *BufPane) JumpLine() bool {
-	InfoBar.Prompt("> ", "goto ", "Command", nil, func(resp string, canceled bool) {
-		if !canceled {
-			h.HandleCommand(resp)
-		}
-	})
+	h.commandPrompt("goto ")
 	return true
 }
 
@@ -1530,6 +1898,7 @@ func (h *BufPane) ToggleDiffGutter() bool {
 		h.Buf.Settings["diffgutter"] = false
 		InfoBar.Message("Disabled diff gutter")
 	}
+	config.RememberView("diffgutter", h.Buf.Settings["diffgutter"].(bool))
 	return true
 }
 
@@ -1542,6 +1911,76 @@ func (h *BufPane) ToggleRuler() bool {
 		h.Buf.Settings["ruler"] = false
 		InfoBar.Message("Disabled ruler")
 	}
+	config.RememberView("ruler", h.Buf.Settings["ruler"].(bool))
+	return true
+}
+
+// ToggleRelativeRuler switches line numbers between absolute and relative to
+// the cursor line, turning them on if needed
+func (h *BufPane) ToggleRelativeRuler() bool {
+	if !h.Buf.Settings["relativeruler"].(bool) {
+		h.Buf.Settings["relativeruler"] = true
+		InfoBar.Message("Enabled relative line numbers")
+	} else {
+		h.Buf.Settings["relativeruler"] = false
+		InfoBar.Message("Disabled relative line numbers")
+	}
+	config.RememberView("relativeruler", h.Buf.Settings["relativeruler"].(bool))
+	if !h.Buf.Settings["ruler"].(bool) {
+		h.Buf.Settings["ruler"] = true
+		config.RememberView("ruler", true)
+	}
+	return true
+}
+
+// ToggleSoftwrap turns soft wrapping on or off for the current buffer. Only
+// the display changes, the lines of the buffer are left as they are
+func (h *BufPane) ToggleSoftwrap() bool {
+	softwrap := !h.Buf.Settings["softwrap"].(bool)
+	h.Buf.SetOptionNative("softwrap", softwrap)
+	if softwrap {
+		InfoBar.Message("Enabled soft wrap")
+	} else {
+		InfoBar.Message("Disabled soft wrap")
+	}
+	return true
+}
+
+// ToggleIndentGuide turns indent guides on or off
+func (h *BufPane) ToggleIndentGuide() bool {
+	if !h.Buf.Settings["indentguide"].(bool) {
+		h.Buf.Settings["indentguide"] = true
+		InfoBar.Message("Enabled indent guides")
+	} else {
+		h.Buf.Settings["indentguide"] = false
+		InfoBar.Message("Disabled indent guides")
+	}
+	config.RememberView("indentguide", h.Buf.Settings["indentguide"].(bool))
+	return true
+}
+
+// ToggleStickyHeader turns the sticky header on or off
+func (h *BufPane) ToggleStickyHeader() bool {
+	if !h.Buf.Settings["stickyheader"].(bool) {
+		h.Buf.Settings["stickyheader"] = true
+		InfoBar.Message("Enabled sticky header")
+	} else {
+		h.Buf.Settings["stickyheader"] = false
+		InfoBar.Message("Disabled sticky header")
+	}
+	config.RememberView("stickyheader", h.Buf.Settings["stickyheader"].(bool))
+	return true
+}
+
+// ToggleSyntax turns syntax highlighting on or off for the current buffer
+func (h *BufPane) ToggleSyntax() bool {
+	if !h.Buf.Settings["syntax"].(bool) {
+		h.Buf.SetOptionNative("syntax", true)
+		InfoBar.Message("Enabled syntax highlighting")
+	} else {
+		h.Buf.SetOptionNative("syntax", false)
+		InfoBar.Message("Disabled syntax highlighting")
+	}
 	return true
 }
 
@@ -1564,6 +2003,7 @@ func (h *BufPane) ToggleHelp() bool {
 // ToggleKeyMenu toggles the keymenu option and resizes all tabs
 func (h *BufPane) ToggleKeyMenu() bool {
 	config.GlobalSettings["keymenu"] = !config.GetGlobalOption("keymenu").(bool)
+	config.RememberView("keymenu", config.GetGlobalOption("keymenu").(bool))
 	Tabs.Resize()
 	return true
 }
@@ -1582,12 +2022,50 @@ func (h *BufPane) ShellMode() bool {
 
 // CommandMode lets the user enter a command
 func (h *BufPane) CommandMode() bool {
-	InfoBar.Prompt("> ", "", "Command", nil, func(resp string, canceled bool) {
+	h.commandPrompt("")
+	return true
+}
+
+// commandPrompt opens the command prompt with the given text. While a goto
+// command is typed, the cursor previews its target, and goes back where it
+// was when the prompt is closed, so that only the actual jump is recorded in
+// the location history
+func (h *BufPane) commandPrompt(text string) {
+	orig, view := h.Cursor.Loc, *h.GetView()
+	previewing := false
+	restore := func() {
+		if previewing {
+			h.Cursor.GotoLoc(orig)
+			v := view
+			h.SetView(&v)
+			previewing = false
+		}
+	}
+	preview := func(resp string) {
+		args := strings.Fields(resp)
+		if len(args) != 2 || args[0] != "goto" {
+			restore()
+			return
+		}
+		// relative targets are counted from the line the prompt was
+		// opened on, not from the previous preview
+		restore()
+		loc, err := h.gotoTarget(args[1:])
+		if err != nil {
+			return
+		}
+		traversingHistory = true
+		h.GotoLoc(loc)
+		traversingHistory = false
+		previewing = true
+	}
+
+	InfoBar.Prompt("> ", text, "Command", preview, func(resp string, canceled bool) {
+		restore()
 		if !canceled {
 			h.HandleCommand(resp)
 		}
 	})
-	return true
 }
 
 // ToggleOverwriteMode lets the user toggle the text overwrite mode
@@ -1598,6 +2076,8 @@ func (h *BufPane) ToggleOverwriteMode() bool {
 
 // Escape leaves current mode
 func (h *BufPane) Escape() bool {
+	// leave the snippet being filled in
+	h.snippet = nil
 	return true
 }
 
@@ -1616,12 +2096,14 @@ func (h *BufPane) ClearInfo() bool {
 // ForceQuit closes the current tab or view even if there are unsaved changes
 // (no prompt)
 func (h *BufPane) ForceQuit() bool {
+	h.closeDiffHead()
 	h.Buf.Close()
 	if len(MainTab().Panes) > 1 {
 		h.Unsplit()
 	} else if len(Tabs.List) > 1 {
 		Tabs.RemoveTab(h.splitID)
 	} else {
+		saveState()
 		screen.Screen.Fini()
 		InfoBar.Close()
 		runtime.Goexit()
@@ -1665,6 +2147,7 @@ func (h *BufPane) QuitAll() bool {
 	}
 
 	quit := func() {
+		saveState()
 		buffer.CloseOpenBuffers()
 		screen.Screen.Fini()
 		InfoBar.Close()
@@ -1672,10 +2155,17 @@ func (h *BufPane) QuitAll() bool {
 	}
 
 	if anyModified {
-		InfoBar.YNPrompt("Quit macro? (all open buffers will be closed without saving)", func(yes, canceled bool) {
-			if !canceled && yes {
-				quit()
+		InfoBar.YNSavePrompt("Quit macro? All open buffers will be closed without saving (y,n,s to save all and quit)", func(yes, save, canceled bool) {
+			if canceled || !yes {
+				return
 			}
+			if save {
+				if _, _, errs := saveModifiedBuffers(); len(errs) > 0 {
+					InfoBar.Error("Not quitting: ", joinErrors(errs))
+					return
+				}
+			}
+			quit()
 		})
 	} else {
 		quit()
@@ -1769,13 +2259,92 @@ func (h *BufPane) PreviousSplit() bool {
 	return true
 }
 
+// LocationBack goes back to the previous significant location (jump or
+// buffer switch) in the location history
+func (h *BufPane) LocationBack() bool {
+	p, ok := locHistory.Back(h.position())
+	if !ok {
+		InfoBar.Message("No previous location")
+		return false
+	}
+	return h.gotoPosition(p)
+}
+
+// LocationForward goes forward to the next location in the location history
+func (h *BufPane) LocationForward() bool {
+	p, ok := locHistory.Forward()
+	if !ok {
+		InfoBar.Message("No next location")
+		return false
+	}
+	return h.gotoPosition(p)
+}
+
+// gotoPosition switches to the pane displaying the file of the given
+// position, opening it in a new tab if needed, and moves its cursor there
+func (h *BufPane) gotoPosition(p buffer.Position) bool {
+	traversingHistory = true
+	defer func() { traversingHistory = false }()
+
+	target := h
+	if h.Buf.AbsPath != p.Path {
+		target = nil
+		for i, t := range Tabs.List {
+			for j, pane := range t.Panes {
+				if bp, ok := pane.(*BufPane); ok && bp.Buf.AbsPath == p.Path {
+					Tabs.SetActive(i)
+					t.SetActive(j)
+					target = bp
+					break
+				}
+			}
+			if target != nil {
+				break
+			}
+		}
+	}
+	if target == nil {
+		b, err := buffer.NewBufferFromFile(p.Path, buffer.BTDefault)
+		if err != nil {
+			InfoBar.Error(err)
+			return false
+		}
+		width, height := screen.Screen.Size()
+		iOffset := config.GetInfoBarOffset()
+		tp := NewTabFromBuffer(0, 0, width, height-1-iOffset, b)
+		Tabs.AddTab(tp)
+		Tabs.SetActive(len(Tabs.List) - 1)
+		target = MainTab().CurPane()
+	}
+
+	// the file may have changed since the position was recorded
+	y := util.Clamp(p.Loc.Y, 0, target.Buf.LinesNum()-1)
+	x := util.Clamp(p.Loc.X, 0, util.CharacterCount(target.Buf.LineBytes(y)))
+	target.GotoLoc(buffer.Loc{X: x, Y: y})
+	return true
+}
+
 var curmacro []interface{}
 var recordingMacro bool
 
+// prevmacro is the macro recorded before the current recording, which
+// CancelMacro restores
+var prevmacro []interface{}
+
+// recordingStatus is the $(recording) statusline function, which shows
+// whether a macro is being recorded
+func recordingStatus(b *buffer.Buffer) string {
+	if recordingMacro {
+		return "REC "
+	}
+	return ""
+}
+
 // ToggleMacro toggles recording of a macro
 func (h *BufPane) ToggleMacro() bool {
 	recordingMacro = !recordingMacro
 	if recordingMacro {
+		prevmacro = curmacro
 		curmacro = []interface{}{}
 		InfoBar.Message("Recording")
 	} else {
@@ -1785,6 +2354,18 @@ func (h *BufPane) ToggleMacro() bool {
 	return true
 }
 
+// CancelMacro stops recording a macro and discards it, so that PlayMacro
+// plays the previously recorded macro
+func (h *BufPane) CancelMacro() bool {
+	if !recordingMacro {
+		return false
+	}
+	recordingMacro = false
+	curmacro = prevmacro
+	InfoBar.Message("Canceled recording")
+	return true
+}
+
 // PlayMacro plays back the most recently recorded macro
 func (h *BufPane) PlayMacro() bool {
 	if recordingMacro {
@@ -0,0 +1,19 @@
+package action
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zyedidia/micro/v2/internal/config"
+)
+
+func TestRecordingStatus(t *testing.T) {
+	defer func() { recordingMacro = false }()
+
+	assert.Contains(t, config.DefaultCommonSettings()["statusformatl"], "$(recording)")
+
+	recordingMacro = false
+	assert.Equal(t, "", recordingStatus(nil))
+	recordingMacro = true
+	assert.Equal(t, "REC ", recordingStatus(nil))
+}
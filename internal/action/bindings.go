@@ -8,6 +8,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 	"unicode"
 
@@ -57,6 +58,10 @@ func InitBindings() {
 		}
 	}
 
+	for _, c := range checkBindingsConflicts(parsed) {
+		screen.TermMessage(c.Error())
+	}
+
 	for k, v := range parsed {
 		switch val := v.(type) {
 		case string:
@@ -81,6 +86,72 @@ func InitBindings() {
 	}
 }
 
+// A Conflict is a set of keys which refer to the same event but are bound
+// to different actions, so that only one of them takes effect
+type Conflict struct {
+	Pane string
+	Keys []string
+}
+
+func (c Conflict) Error() string {
+	return fmt.Sprintf("Conflicting %s bindings in bindings.json: %s refer to the same key", c.Pane, strings.Join(c.Keys, ", "))
+}
+
+// CheckKeybindingConflicts returns the conflicts between the given bindings
+// of a pane type
+func CheckKeybindingConflicts(pane string, bindings map[string]string) []Conflict {
+	keys := make(map[string][]string)
+	for k := range bindings {
+		if e, err := findEvent(k); err == nil {
+			keys[e.Name()] = append(keys[e.Name()], k)
+		}
+	}
+
+	var conflicts []Conflict
+	for _, ks := range keys {
+		sort.Strings(ks)
+		for _, k := range ks[1:] {
+			if bindings[k] != bindings[ks[0]] {
+				conflicts = append(conflicts, Conflict{pane, ks})
+				break
+			}
+		}
+	}
+	sort.Slice(conflicts, func(i, j int) bool {
+		return conflicts[i].Keys[0] < conflicts[j].Keys[0]
+	})
+	return conflicts
+}
+
+// checkBindingsConflicts returns the conflicts between the bindings of
+// every pane type in the parsed bindings.json
+func checkBindingsConflicts(parsed map[string]interface{}) []Conflict {
+	panes := make(map[string]map[string]string)
+	add := func(pane, k string, v interface{}) {
+		if s, ok := v.(string); ok {
+			if panes[pane] == nil {
+				panes[pane] = make(map[string]string)
+			}
+			panes[pane][k] = s
+		}
+	}
+	for k, v := range parsed {
+		if val, ok := v.(map[string]interface{}); ok {
+			for e, a := range val {
+				add(k, e, a)
+			}
+		} else {
+			add("buffer", k, v)
+		}
+	}
+
+	var conflicts []Conflict
+	for pane, bindings := range panes {
+		conflicts = append(conflicts, CheckKeybindingConflicts(pane, bindings)...)
+	}
+	return conflicts
+}
+
 func BindKey(k, v string, bind func(e Event, a string)) {
 	event, err := findEvent(k)
 	if err != nil {
@@ -0,0 +1,27 @@
+package action
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckKeybindingConflicts(t *testing.T) {
+	conflicts := CheckKeybindingConflicts("buffer", map[string]string{
+		"Ctrl-s": "Save",
+		"CtrlS":  "SaveAs",
+		"Ctrl-q": "Quit",
+		"CtrlQ":  "Quit",
+		"Alt-x":  "SkipMultiCursor",
+	})
+	assert.Equal(t, []Conflict{{"buffer", []string{"Ctrl-s", "CtrlS"}}}, conflicts)
+
+	conflicts = checkBindingsConflicts(map[string]interface{}{
+		"Ctrl-s": "Save",
+		"command": map[string]interface{}{
+			"Ctrl-s": "AbortCommand",
+			"CtrlS":  "ExecuteCommand",
+		},
+	})
+	assert.Equal(t, []Conflict{{"command", []string{"Ctrl-s", "CtrlS"}}}, conflicts)
+}
@@ -43,6 +43,7 @@ func BufMouseActionGeneral(a BufMouseAction) PaneMouseAction {
 
 func init() {
 	BufBindings = NewKeyTree()
+	display.SetStatusInfoFn("recording", recordingStatus)
 }
 
 // LuaAction makes an action from a lua function. It returns either a BufKeyAction
@@ -250,6 +251,19 @@ type BufPane struct {
 
 	// remember original location of a search in case the search is canceled
 	searchOrig buffer.Loc
+	// whether the search being entered in the find prompt is a regex search
+	searchRegex bool
+
+	// the selections the current one was expanded from by ExpandSelection,
+	// the current one last, so that ShrinkSelection can go back to them
+	expandStack [][2]buffer.Loc
+
+	// the snippet being filled in, if any
+	snippet *snippetState
+	// the outline shown by this pane, if it is one
+	outline *outline
+	// the HEAD version shown by this pane, if it is one
+	diffHead *diffHead
 
 	// The pane may not yet be fully initialized after its creation
 	// since we may not know the window geometry yet. In such case we finish
@@ -344,6 +358,8 @@ func (h *BufPane) resetMouse() {
 
 // OpenBuffer opens the given buffer in this pane.
 func (h *BufPane) OpenBuffer(b *buffer.Buffer) {
+	recordLocation(h)
+	h.closeDiffHead()
 	h.Buf.Close()
 	h.Buf = b
 	h.BWindow.SetBuffer(b)
@@ -357,6 +373,7 @@ func (h *BufPane) OpenBuffer(b *buffer.Buffer) {
 	// mode when editor is opened
 	h.isOverwriteMode = false
 	h.lastClickTime = time.Time{}
+	syncOutlines()
 }
 
 // GotoLoc moves the cursor to a new location and adjusts the view accordingly.
@@ -364,6 +381,7 @@ func (h *BufPane) OpenBuffer(b *buffer.Buffer) {
 func (h *BufPane) GotoLoc(loc buffer.Loc) {
 	sloc := h.SLocFromLoc(loc)
 	d := h.Diff(h.SLocFromLoc(h.Cursor.Loc), sloc)
+	from := h.Cursor.Loc
 
 	h.Cursor.GotoLoc(loc)
 
@@ -371,6 +389,9 @@ func (h *BufPane) GotoLoc(loc buffer.Loc) {
 	// ensure the cursor is at 25% of the window height
 	height := h.BufView().Height
 	if util.Abs(d) >= height {
+		if !traversingHistory {
+			locHistory.Record(buffer.Position{Path: h.Buf.AbsPath, Loc: from})
+		}
 		v := h.GetView()
 		v.StartLine = h.Scroll(sloc, -height/4)
 		h.ScrollAdjust()
@@ -379,6 +400,19 @@ func (h *BufPane) GotoLoc(loc buffer.Loc) {
 	h.Relocate()
 }
 
+// position returns the current position of the pane's cursor in its file
+func (h *BufPane) position() buffer.Position {
+	return buffer.Position{Path: h.Buf.AbsPath, Loc: h.Cursor.Loc}
+}
+
+// recordLocation records the position of the given pane in the location
+// history, as the user is about to switch away from it
+func recordLocation(p Pane) {
+	if h, ok := p.(*BufPane); ok && h != nil && !traversingHistory {
+		locHistory.Record(h.position())
+	}
+}
+
 func (h *BufPane) initialRelocate() {
 	sloc := h.SLocFromLoc(h.Cursor.Loc)
 	height := h.BufView().Height
@@ -549,6 +583,16 @@ func (h *BufPane) execAction(action BufAction, name string, cursor int, te *tcel
 		h.Buf.HasSuggestions = false
 	}
 
+	if IsDisabled(name) {
+		return false
+	}
+	if a, ok := outlineActions[name]; ok && h.outline != nil {
+		action = a
+	}
+	if a, ok := diffHeadActions[name]; ok && h.diffHead != nil {
+		action = a
+	}
+
 	_, isMulti := MultiActions[name]
 	if (!isMulti && cursor == 0) || isMulti {
 		if h.PluginCB("pre" + name) {
@@ -563,7 +607,7 @@ func (h *BufPane) execAction(action BufAction, name string, cursor int, te *tcel
 
 			if isMulti {
 				if recordingMacro {
-					if name != "ToggleMacro" && name != "PlayMacro" {
+					if name != "ToggleMacro" && name != "PlayMacro" && name != "CancelMacro" {
 						curmacro = append(curmacro, action)
 					}
 				}
@@ -721,6 +765,10 @@ var BufKeyActions = map[string]BufKeyAction{
 	"DeleteWordRight":           (*BufPane).DeleteWordRight,
 	"DeleteWordLeft":            (*BufPane).DeleteWordLeft,
 	"SelectLine":                (*BufPane).SelectLine,
+	"SelectWord":                (*BufPane).SelectWord,
+	"SelectParagraph":           (*BufPane).SelectParagraph,
+	"ExpandSelection":           (*BufPane).ExpandSelection,
+	"ShrinkSelection":           (*BufPane).ShrinkSelection,
 	"SelectToStartOfLine":       (*BufPane).SelectToStartOfLine,
 	"SelectToStartOfText":       (*BufPane).SelectToStartOfText,
 	"SelectToStartOfTextToggle": (*BufPane).SelectToStartOfTextToggle,
@@ -730,18 +778,27 @@ var BufKeyActions = map[string]BufKeyAction{
 	"SelectParagraphPrevious":   (*BufPane).SelectParagraphPrevious,
 	"SelectParagraphNext":       (*BufPane).SelectParagraphNext,
 	"InsertNewline":             (*BufPane).InsertNewline,
+	"InsertLineBelow":           (*BufPane).InsertLineBelow,
+	"InsertLineAbove":           (*BufPane).InsertLineAbove,
 	"Backspace":                 (*BufPane).Backspace,
 	"Delete":                    (*BufPane).Delete,
 	"InsertTab":                 (*BufPane).InsertTab,
 	"Save":                      (*BufPane).Save,
 	"SaveAll":                   (*BufPane).SaveAll,
 	"SaveAs":                    (*BufPane).SaveAs,
+	"Reopen":                    (*BufPane).Reopen,
 	"Find":                      (*BufPane).Find,
 	"FindLiteral":               (*BufPane).FindLiteral,
 	"FindNext":                  (*BufPane).FindNext,
 	"FindPrevious":              (*BufPane).FindPrevious,
 	"DiffNext":                  (*BufPane).DiffNext,
 	"DiffPrevious":              (*BufPane).DiffPrevious,
+	"ToggleBookmark":            (*BufPane).ToggleBookmark,
+	"NextBookmark":              (*BufPane).NextBookmark,
+	"PreviousBookmark":          (*BufPane).PreviousBookmark,
+	"Snippet":                   (*BufPane).Snippet,
+	"NextGutterMessage":         (*BufPane).NextGutterMessage,
+	"PreviousGutterMessage":     (*BufPane).PreviousGutterMessage,
 	"Center":                    (*BufPane).Center,
 	"Undo":                      (*BufPane).Undo,
 	"Redo":                      (*BufPane).Redo,
@@ -779,6 +836,11 @@ var BufKeyActions = map[string]BufKeyAction{
 	"ToggleKeyMenu":             (*BufPane).ToggleKeyMenu,
 	"ToggleDiffGutter":          (*BufPane).ToggleDiffGutter,
 	"ToggleRuler":               (*BufPane).ToggleRuler,
+	"ToggleRelativeRuler":       (*BufPane).ToggleRelativeRuler,
+	"ToggleSoftwrap":            (*BufPane).ToggleSoftwrap,
+	"ToggleIndentGuide":         (*BufPane).ToggleIndentGuide,
+	"ToggleStickyHeader":        (*BufPane).ToggleStickyHeader,
+	"ToggleSyntax":              (*BufPane).ToggleSyntax,
 	"ToggleHighlightSearch":     (*BufPane).ToggleHighlightSearch,
 	"UnhighlightSearch":         (*BufPane).UnhighlightSearch,
 	"ClearStatus":               (*BufPane).ClearStatus,
@@ -795,10 +857,13 @@ var BufKeyActions = map[string]BufKeyAction{
 	"NextSplit":                 (*BufPane).NextSplit,
 	"PreviousSplit":             (*BufPane).PreviousSplit,
 	"Unsplit":                   (*BufPane).Unsplit,
+	"LocationBack":              (*BufPane).LocationBack,
+	"LocationForward":           (*BufPane).LocationForward,
 	"VSplit":                    (*BufPane).VSplitAction,
 	"HSplit":                    (*BufPane).HSplitAction,
 	"ToggleMacro":               (*BufPane).ToggleMacro,
 	"PlayMacro":                 (*BufPane).PlayMacro,
+	"CancelMacro":               (*BufPane).CancelMacro,
 	"Suspend":                   (*BufPane).Suspend,
 	"ScrollUp":                  (*BufPane).ScrollUpAction,
 	"ScrollDown":                (*BufPane).ScrollDownAction,
@@ -810,6 +875,8 @@ var BufKeyActions = map[string]BufKeyAction{
 	"RemoveAllMultiCursors":     (*BufPane).RemoveAllMultiCursors,
 	"SkipMultiCursor":           (*BufPane).SkipMultiCursor,
 	"JumpToMatchingBrace":       (*BufPane).JumpToMatchingBrace,
+	"JumpToBlockStart":          (*BufPane).JumpToBlockStart,
+	"JumpToBlockEnd":            (*BufPane).JumpToBlockEnd,
 	"JumpLine":                  (*BufPane).JumpLine,
 	"Deselect":                  (*BufPane).Deselect,
 	"ClearInfo":                 (*BufPane).ClearInfo,
@@ -853,6 +920,8 @@ var MultiActions = map[string]bool{
 	"DeleteWordRight":           true,
 	"DeleteWordLeft":            true,
 	"SelectLine":                true,
+	"SelectWord":                true,
+	"SelectParagraph":           true,
 	"SelectToStartOfLine":       true,
 	"SelectToStartOfText":       true,
 	"SelectToStartOfTextToggle": true,
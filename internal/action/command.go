@@ -2,15 +2,19 @@ package action
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"math"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
+	humanize "github.com/dustin/go-humanize"
 	shellquote "github.com/kballard/go-shellquote"
 	"github.com/zyedidia/micro/v2/internal/buffer"
 	"github.com/zyedidia/micro/v2/internal/clipboard"
@@ -31,39 +35,62 @@ var commands map[string]Command
 
 func InitCommands() {
 	commands = map[string]Command{
-		"set":        {(*BufPane).SetCmd, OptionValueComplete},
-		"reset":      {(*BufPane).ResetCmd, OptionValueComplete},
-		"setlocal":   {(*BufPane).SetLocalCmd, OptionValueComplete},
-		"show":       {(*BufPane).ShowCmd, OptionComplete},
-		"showkey":    {(*BufPane).ShowKeyCmd, nil},
-		"run":        {(*BufPane).RunCmd, nil},
-		"bind":       {(*BufPane).BindCmd, nil},
-		"unbind":     {(*BufPane).UnbindCmd, nil},
-		"quit":       {(*BufPane).QuitCmd, nil},
-		"goto":       {(*BufPane).GotoCmd, nil},
-		"jump":       {(*BufPane).JumpCmd, nil},
-		"save":       {(*BufPane).SaveCmd, nil},
-		"replace":    {(*BufPane).ReplaceCmd, nil},
-		"replaceall": {(*BufPane).ReplaceAllCmd, nil},
-		"vsplit":     {(*BufPane).VSplitCmd, buffer.FileComplete},
-		"hsplit":     {(*BufPane).HSplitCmd, buffer.FileComplete},
-		"tab":        {(*BufPane).NewTabCmd, buffer.FileComplete},
-		"help":       {(*BufPane).HelpCmd, HelpComplete},
-		"eval":       {(*BufPane).EvalCmd, nil},
-		"log":        {(*BufPane).ToggleLogCmd, nil},
-		"plugin":     {(*BufPane).PluginCmd, PluginComplete},
-		"reload":     {(*BufPane).ReloadCmd, nil},
-		"reopen":     {(*BufPane).ReopenCmd, nil},
-		"cd":         {(*BufPane).CdCmd, buffer.FileComplete},
-		"pwd":        {(*BufPane).PwdCmd, nil},
-		"open":       {(*BufPane).OpenCmd, buffer.FileComplete},
-		"tabmove":    {(*BufPane).TabMoveCmd, nil},
-		"tabswitch":  {(*BufPane).TabSwitchCmd, nil},
-		"term":       {(*BufPane).TermCmd, nil},
-		"memusage":   {(*BufPane).MemUsageCmd, nil},
-		"retab":      {(*BufPane).RetabCmd, nil},
-		"raw":        {(*BufPane).RawCmd, nil},
-		"textfilter": {(*BufPane).TextFilterCmd, nil},
+		"set":          {(*BufPane).SetCmd, OptionValueComplete},
+		"reset":        {(*BufPane).ResetCmd, OptionValueComplete},
+		"setlocal":     {(*BufPane).SetLocalCmd, OptionValueComplete},
+		"show":         {(*BufPane).ShowCmd, OptionComplete},
+		"showkey":      {(*BufPane).ShowKeyCmd, nil},
+		"run":          {(*BufPane).RunCmd, nil},
+		"bind":         {(*BufPane).BindCmd, nil},
+		"unbind":       {(*BufPane).UnbindCmd, nil},
+		"quit":         {(*BufPane).QuitCmd, nil},
+		"forcequit":    {(*BufPane).ForceQuitCmd, nil},
+		"closeothers":  {(*BufPane).CloseOthersCmd, nil},
+		"goto":         {(*BufPane).GotoCmd, nil},
+		"jump":         {(*BufPane).JumpCmd, nil},
+		"save":         {(*BufPane).SaveCmd, nil},
+		"saveall":      {(*BufPane).SaveAllCmd, nil},
+		"savetemplate": {(*BufPane).SaveTemplateCmd, nil},
+		"replace":      {(*BufPane).ReplaceCmd, nil},
+		"replaceall":   {(*BufPane).ReplaceAllCmd, nil},
+		"vsplit":       {(*BufPane).VSplitCmd, buffer.FileComplete},
+		"hsplit":       {(*BufPane).HSplitCmd, buffer.FileComplete},
+		"diffhead":     {(*BufPane).DiffHeadCmd, nil},
+		"tab":          {(*BufPane).NewTabCmd, buffer.FileComplete},
+		"help":         {(*BufPane).HelpCmd, HelpComplete},
+		"eval":         {(*BufPane).EvalCmd, nil},
+		"log":          {(*BufPane).ToggleLogCmd, nil},
+		"plugin":       {(*BufPane).PluginCmd, PluginComplete},
+		"state":        {(*BufPane).StateCmd, StateComplete},
+		"recent":       {(*BufPane).RecentCmd, RecentComplete},
+		"findfile":     {(*BufPane).FindFileCmd, ProjectFileComplete},
+		"timestamp":    {(*BufPane).TimestampCmd, TimestampComplete},
+		"reload":       {(*BufPane).ReloadCmd, nil},
+		"reopen":       {(*BufPane).ReopenCmd, nil},
+		"cd":           {(*BufPane).CdCmd, buffer.FileComplete},
+		"pwd":          {(*BufPane).PwdCmd, nil},
+		"open":         {(*BufPane).OpenCmd, buffer.FileComplete},
+		"tabmove":      {(*BufPane).TabMoveCmd, nil},
+		"tabswitch":    {(*BufPane).TabSwitchCmd, nil},
+		"term":         {(*BufPane).TermCmd, nil},
+		"memusage":     {(*BufPane).MemUsageCmd, nil},
+		"retab":        {(*BufPane).RetabCmd, nil},
+		"reindent":     {(*BufPane).ReindentCmd, nil},
+		"reverse":      {(*BufPane).ReverseCmd, nil},
+		"sort":         {(*BufPane).SortCmd, nil},
+		"surround":     {(*BufPane).SurroundCmd, nil},
+		"encode":       {(*BufPane).EncodeCmd, EncodingComplete},
+		"decode":       {(*BufPane).DecodeCmd, EncodingComplete},
+		"pretty":       {(*BufPane).PrettyCmd, PrettyComplete},
+		"minify":       {(*BufPane).MinifyCmd, PrettyComplete},
+		"trimws":       {(*BufPane).TrimWsCmd, nil},
+		"bookmark":     {(*BufPane).BookmarkCmd, nil},
+		"bookmarks":    {(*BufPane).BookmarksCmd, BookmarkComplete},
+		"symbol":       {(*BufPane).SymbolCmd, SymbolComplete},
+		"outline":      {(*BufPane).OutlineCmd, nil},
+		"format":       {(*BufPane).FormatCmd, nil},
+		"raw":          {(*BufPane).RawCmd, nil},
+		"textfilter":   {(*BufPane).TextFilterCmd, nil},
 	}
 }
 
@@ -75,16 +102,23 @@ func MakeCommand(name string, action func(bp *BufPane, args []string), completer
 	}
 }
 
+// IsDisabled returns true if the given command or action has been disabled
+// with the disabledcommands option
+func IsDisabled(name string) bool {
+	for _, d := range config.GetGlobalStrings("disabledcommands") {
+		if d == name {
+			return true
+		}
+	}
+	return false
+}
+
 // CommandEditAction returns a bindable function that opens a prompt with
 // the given string and executes the command when the user presses
 // enter
 func CommandEditAction(prompt string) BufKeyAction {
 	return func(h *BufPane) bool {
-		InfoBar.Prompt("> ", prompt, "Command", nil, func(resp string, canceled bool) {
-			if !canceled {
-				MainTab().CurPane().HandleCommand(resp)
-			}
-		})
+		h.commandPrompt(prompt)
 		return false
 	}
 }
@@ -114,12 +148,417 @@ func (h *BufPane) PluginCmd(args []string) {
 	config.PluginCommand(buffer.LogBuf, args[0], args[1:])
 }
 
+var StateCmds = []string{"show", "clear"}
+
+// StateCmd shows where macro persists state between sessions (cursor
+// positions, undo and command history), or clears all of it
+func (h *BufPane) StateCmd(args []string) {
+	if len(args) < 1 {
+		InfoBar.Error("Not enough arguments")
+		return
+	}
+
+	switch args[0] {
+	case "show":
+		entries := config.ShowState()
+		if len(entries) == 0 {
+			InfoBar.Message("No persisted state")
+			return
+		}
+		if h.Buf.Type != buffer.BTLog {
+			h.OpenLogBuf()
+		}
+		for _, e := range entries {
+			buffer.WriteLog(fmt.Sprintf("%s (%s)\n", e.Path, humanize.Bytes(uint64(e.Size))))
+		}
+	case "clear":
+		InfoBar.YNPrompt("Clear all persisted state? (y,n,esc)", func(yes, canceled bool) {
+			if !yes || canceled {
+				return
+			}
+			if err := config.ClearState(); err != nil {
+				InfoBar.Error(err)
+				return
+			}
+			InfoBar.History = make(map[string][]string)
+			InfoBar.Message("Cleared persisted state")
+		})
+	default:
+		InfoBar.Error("Invalid state command: ", args[0])
+	}
+}
+
+// RecentCmd opens one of the files opened most recently, or lists them if
+// no file is given
+func (h *BufPane) RecentCmd(args []string) {
+	if len(args) > 0 {
+		h.OpenCmd([]string{strings.Join(args, " ")})
+		return
+	}
+
+	files := config.RecentFiles()
+	if len(files) == 0 {
+		InfoBar.Message("No recent files")
+		return
+	}
+	if h.Buf.Type != buffer.BTLog {
+		h.OpenLogBuf()
+	}
+	for _, f := range files {
+		buffer.WriteLog(util.CollapseHome(f) + "\n")
+	}
+}
+
+// FindFileCmd opens a file of the project, found by completing a fuzzy
+// pattern over the files of the current directory tree. Without argument,
+// the project is indexed again and the command is prompted for
+func (h *BufPane) FindFileCmd(args []string) {
+	if len(args) > 0 {
+		h.OpenCmd([]string{strings.Join(args, " ")})
+		return
+	}
+
+	indexProject(true)
+	InfoBar.Prompt("> ", "findfile ", "Command", nil, func(resp string, canceled bool) {
+		if !canceled {
+			h.HandleCommand(resp)
+		}
+	})
+}
+
+// TimestampCmd inserts the current date and time at the cursor, in the given
+// format or else the one of the timestampformat option
+func (h *BufPane) TimestampCmd(args []string) {
+	if h.Buf.Type.Readonly {
+		InfoBar.Error("Cannot modify a read-only buffer")
+		return
+	}
+
+	format := h.Buf.Settings["timestampformat"].(string)
+	if len(args) > 0 {
+		format = strings.Join(args, " ")
+	}
+
+	if h.Cursor.HasSelection() {
+		h.Cursor.DeleteSelection()
+		h.Cursor.ResetSelection()
+	}
+	h.Buf.Insert(h.Cursor.Loc, util.FormatTimestamp(format, time.Now()))
+	h.Relocate()
+}
+
 // RetabCmd changes all spaces to tabs or all tabs to spaces
 // depending on the user's settings
 func (h *BufPane) RetabCmd(args []string) {
 	h.Buf.Retab()
 }
 
+// ReindentCmd normalizes the indentation of every line to the
+// tabsize and tabstospaces settings of the buffer
+func (h *BufPane) ReindentCmd(args []string) {
+	if h.Buf.Type.Readonly {
+		InfoBar.Error("Cannot reindent a read-only buffer")
+		return
+	}
+	n := h.Buf.Reindent()
+	h.Relocate()
+	InfoBar.Message(fmt.Sprintf("Reindented %d lines", n))
+}
+
+// ReverseCmd reverses the order of the selected lines, or of all the
+// lines of the buffer if there is no selection
+func (h *BufPane) ReverseCmd(args []string) {
+	h.transformLines(util.ReverseLines)
+}
+
+// SortCmd sorts the selected lines, or all the lines of the buffer if
+// nothing is selected
+func (h *BufPane) SortCmd(args []string) {
+	var numeric, natural, fold, reverse bool
+	for _, a := range args {
+		switch a {
+		case "-n":
+			numeric = true
+		case "-V":
+			natural = true
+		case "-i":
+			fold = true
+		case "-r":
+			reverse = true
+		default:
+			InfoBar.Error("Invalid flag: " + a)
+			return
+		}
+	}
+	// numbers are compared by value, regardless of case
+	if numeric && (natural || fold) {
+		InfoBar.Error("-n cannot be combined with -V or -i")
+		return
+	}
+
+	less := func(a, b string) bool { return a < b }
+	if natural {
+		less = util.NaturalLess
+	}
+	key := func(s string) string { return s }
+	if fold {
+		key = strings.ToLower
+	}
+
+	h.transformLines(func(lines []string) []string {
+		var sorted []string
+		if numeric {
+			sorted = util.SortLinesNumeric(lines)
+		} else {
+			sorted = util.SortLinesFunc(lines, func(a, b string) bool {
+				return less(key(a), key(b))
+			})
+		}
+		if reverse {
+			sorted = util.ReverseLines(sorted)
+		}
+		return sorted
+	})
+}
+
+// TrimWsCmd removes the trailing whitespace of every line of the buffer. The
+// line of the cursor is left alone if the cursor is in its trailing
+// whitespace, so that it doesn't jump while typing
+func (h *BufPane) TrimWsCmd(args []string) {
+	if h.Buf.Type.Readonly {
+		InfoBar.Error("Cannot modify a read-only buffer")
+		return
+	}
+
+	skip := -1
+	l := h.Buf.LineBytes(h.Cursor.Y)
+	ws := util.CharacterCount(util.GetTrailingWhitespace(l))
+	if ws > 0 && h.Cursor.X > util.CharacterCount(l)-ws {
+		skip = h.Cursor.Y
+	}
+
+	n := h.Buf.TrimTrailingWhitespace(skip)
+	InfoBar.Message(fmt.Sprintf("Trimmed %d lines", n))
+	h.Relocate()
+}
+
+// BookmarkCmd bookmarks the current line, or removes its bookmark
+func (h *BufPane) BookmarkCmd(args []string) {
+	h.ToggleBookmark()
+}
+
+// BookmarksCmd jumps to the given bookmarked line, or lists the bookmarked
+// lines if no line is given
+func (h *BufPane) BookmarksCmd(args []string) {
+	marks := h.Buf.Bookmarks()
+	if len(marks) == 0 {
+		InfoBar.Message("No bookmarks")
+		return
+	}
+
+	if len(args) == 0 {
+		lines := make([]string, len(marks))
+		for i, m := range marks {
+			lines[i] = strconv.Itoa(m + 1)
+		}
+		InfoBar.Message("Bookmarks: " + strings.Join(lines, ", "))
+		return
+	}
+
+	line, err := strconv.Atoi(args[0])
+	if err != nil || !h.Buf.Bookmarked(line-1) {
+		InfoBar.Error("No bookmark at line ", args[0])
+		return
+	}
+	h.RemoveAllMultiCursors()
+	h.GotoLoc(buffer.Loc{X: 0, Y: line - 1})
+}
+
+// SymbolCmd jumps to the definition of the given symbol of the buffer. The
+// name may be followed by a colon and the line of the definition, as
+// suggested by the autocompletion, to pick between symbols with the same name
+func (h *BufPane) SymbolCmd(args []string) {
+	if len(args) < 1 {
+		InfoBar.Error("Not enough arguments")
+		return
+	}
+
+	// headings may contain spaces
+	name, line := strings.Join(args, " "), -1
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		if n, err := strconv.Atoi(name[i+1:]); err == nil {
+			name, line = name[:i], n-1
+		}
+	}
+
+	for _, s := range h.Buf.Symbols() {
+		if s.Name == name && (line < 0 || s.Line == line) {
+			h.RemoveAllMultiCursors()
+			h.GotoLoc(buffer.Loc{X: 0, Y: s.Line})
+			return
+		}
+	}
+	InfoBar.Error("No symbol ", strings.Join(args, " "))
+}
+
+// SurroundCmd wraps the selection, or the current line without its
+// indentation, with the given template
+func (h *BufPane) SurroundCmd(args []string) {
+	if len(args) < 1 {
+		InfoBar.Error("Not enough arguments")
+		return
+	}
+	if h.Buf.Type.Readonly {
+		InfoBar.Error("Cannot modify a read-only buffer")
+		return
+	}
+
+	start, end := h.selectionOrLine()
+	before, after := util.SurroundParts(args[0])
+	text := before + string(h.Buf.Substr(start, end))
+	h.Cursor.Deselect(true)
+	h.Buf.Replace(start, end, text+after)
+
+	// leave the cursor at the end of the surrounded text
+	h.Cursor.GotoLoc(start.Move(util.CharacterCountInString(text), h.Buf))
+	h.Relocate()
+}
+
+// PrettyCmd reformats the JSON or XML of the selection, or of the whole
+// buffer, with one element per line
+func (h *BufPane) PrettyCmd(args []string) {
+	indent := h.Buf.IndentString(util.IntOpt(h.Buf.Settings["tabsize"]))
+	h.reformatCmd(args, func(lang, text string) (string, error) {
+		return util.Pretty(lang, text, indent)
+	})
+}
+
+// MinifyCmd removes the insignificant whitespace from the JSON or XML of the
+// selection, or of the whole buffer
+func (h *BufPane) MinifyCmd(args []string) {
+	h.reformatCmd(args, util.Minify)
+}
+
+// reformatCmd replaces the selection, or the whole buffer, by the result of
+// the given function for the language given as argument, or else the
+// filetype of the buffer. The buffer is left untouched on error
+func (h *BufPane) reformatCmd(args []string, fn func(lang, text string) (string, error)) {
+	if h.Buf.Type.Readonly {
+		InfoBar.Error("Cannot modify a read-only buffer")
+		return
+	}
+	lang := h.Buf.FileType()
+	if len(args) > 0 {
+		lang = args[0]
+	}
+
+	start, end := h.Buf.Start(), h.Buf.End()
+	if h.Cursor.HasSelection() {
+		start, end = h.Cursor.CurSelection[0], h.Cursor.CurSelection[1]
+		if end.LessThan(start) {
+			start, end = end, start
+		}
+	}
+	text := string(h.Buf.Substr(start, end))
+	result, err := fn(lang, text)
+	if err != nil {
+		InfoBar.Error(err)
+		return
+	}
+	if !h.Cursor.HasSelection() && strings.HasSuffix(text, "\n") {
+		// keep the final newline of the buffer
+		result += "\n"
+	}
+
+	// keep the cursor on the same line if possible
+	y := h.Cursor.Y
+	h.Cursor.Deselect(true)
+	h.Buf.Replace(start, end, result)
+	h.Cursor.GotoLoc(buffer.Loc{X: 0, Y: util.Min(y, h.Buf.LinesNum()-1)})
+	h.Relocate()
+}
+
+// selectionOrLine returns the bounds of the selection, or else of the current
+// line without its indentation
+func (h *BufPane) selectionOrLine() (buffer.Loc, buffer.Loc) {
+	if h.Cursor.HasSelection() {
+		start, end := h.Cursor.CurSelection[0], h.Cursor.CurSelection[1]
+		if end.LessThan(start) {
+			start, end = end, start
+		}
+		return start, end
+	}
+	line := h.Buf.LineBytes(h.Cursor.Y)
+	start := buffer.Loc{X: util.CharacterCount(util.GetLeadingWhitespace(line)), Y: h.Cursor.Y}
+	end := buffer.Loc{X: util.CharacterCount(line), Y: h.Cursor.Y}
+	return start, end
+}
+
+// EncodeCmd encodes the selection, or the current line, in base64 or hex
+func (h *BufPane) EncodeCmd(args []string) {
+	h.codeCmd(args, util.Encode)
+}
+
+// DecodeCmd decodes the selection, or the current line, from base64 or hex
+func (h *BufPane) DecodeCmd(args []string) {
+	h.codeCmd(args, util.Decode)
+}
+
+// codeCmd replaces the selection, or the current line, by the result of
+// the given encoding function. The buffer is left untouched on error
+func (h *BufPane) codeCmd(args []string, fn func(encoding, text string) (string, error)) {
+	if len(args) < 1 {
+		InfoBar.Error("Not enough arguments")
+		return
+	}
+	if h.Buf.Type.Readonly {
+		InfoBar.Error("Cannot modify a read-only buffer")
+		return
+	}
+
+	start, end := h.selectionOrLine()
+	text, err := fn(args[0], string(h.Buf.Substr(start, end)))
+	if err != nil {
+		InfoBar.Error(err)
+		return
+	}
+
+	h.Cursor.Deselect(true)
+	h.Buf.Replace(start, end, text)
+	h.Cursor.SetSelectionStart(start)
+	h.Cursor.SetSelectionEnd(start.Move(util.CharacterCountInString(text), h.Buf))
+	h.Cursor.OrigSelection = h.Cursor.CurSelection
+	h.Cursor.Loc = h.Cursor.CurSelection[1]
+	h.Relocate()
+}
+
+// transformLines replaces the selected lines, or all the lines of the buffer
+// if there is no selection, with the result of the given function
+func (h *BufPane) transformLines(fn func(lines []string) []string) {
+	if h.Buf.Type.Readonly {
+		InfoBar.Error("Cannot modify a read-only buffer")
+		return
+	}
+
+	start, end := 0, h.Buf.LinesNum()-1
+	if h.Cursor.HasSelection() {
+		first, last := h.Cursor.CurSelection[0], h.Cursor.CurSelection[1]
+		if first.GreaterThan(last) {
+			first, last = last, first
+		}
+		start, end = first.Y, last.Y
+		// a selection ending at the start of a line does not include it
+		if last.X == 0 && end > start {
+			end--
+		}
+	}
+
+	h.Cursor.Deselect(true)
+	h.Buf.TransformLines(start, end, fn)
+	h.Cursor.Relocate()
+	h.Relocate()
+}
+
 // RawCmd opens a new raw view which displays the escape sequences micro
 // is receiving in real-time
 func (h *BufPane) RawCmd(args []string) {
@@ -130,31 +569,77 @@ func (h *BufPane) RawCmd(args []string) {
 	Tabs.SetActive(len(Tabs.List) - 1)
 }
 
-// TextFilterCmd filters the selection through the command.
-// Selection goes to the command input.
-// On successful run command output replaces the current selection.
+// TextFilterCmd filters the selection, or the whole buffer, through the given
+// command. The command runs in the background, and the text is replaced by
+// its output only if it succeeds and the text did not change meanwhile
 func (h *BufPane) TextFilterCmd(args []string) {
 	if len(args) == 0 {
 		InfoBar.Error("usage: textfilter arguments")
 		return
 	}
-	sel := h.Cursor.GetSelection()
-	if len(sel) == 0 {
-		h.Cursor.SelectWord()
-		sel = h.Cursor.GetSelection()
+	if h.Buf.Type.Readonly {
+		InfoBar.Error("Cannot modify a read-only buffer")
+		return
+	}
+
+	start, end := h.Buf.Start(), h.Buf.End()
+	if h.Cursor.HasSelection() {
+		start, end = h.Cursor.CurSelection[0], h.Cursor.CurSelection[1]
+		if end.LessThan(start) {
+			start, end = end, start
+		}
 	}
+	text := string(h.Buf.Substr(start, end))
+	filterText(args, text, func(out string) {
+		if h.Buf.End().LessThan(end) || string(h.Buf.Substr(start, end)) != text {
+			InfoBar.Error("The text changed while running ", args[0])
+			return
+		}
+		h.Cursor.Deselect(true)
+		h.Buf.Replace(start, end, out)
+		h.Relocate()
+	})
+}
+
+// runFilter runs the given command with text as its standard input and
+// returns its standard output. On failure, the error holds the error output
+// of the command if there is one. The command is killed if ctx is done
+// before it exits
+func runFilter(ctx context.Context, args []string, text string) (string, error) {
 	var bout, berr bytes.Buffer
-	cmd := exec.Command(args[0], args[1:]...)
-	cmd.Stdin = strings.NewReader(string(sel))
-	cmd.Stderr = &berr
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdin = strings.NewReader(text)
 	cmd.Stdout = &bout
-	err := cmd.Run()
-	if err != nil {
-		InfoBar.Error(err.Error() + " " + berr.String())
-		return
+	cmd.Stderr = &berr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", errors.New(args[0] + " timed out")
+		}
+		if msg := strings.TrimSpace(berr.String()); msg != "" {
+			return "", errors.New(msg)
+		}
+		return "", fmt.Errorf("%s: %v", args[0], err)
 	}
-	h.Cursor.DeleteSelection()
-	h.Buf.Insert(h.Cursor.Loc, bout.String())
+	return bout.String(), nil
+}
+
+// filterText runs the given command in the background with text as its
+// standard input, and calls done on the main loop with its standard output
+// if it succeeds. Otherwise the error is shown
+func filterText(args []string, text string, done func(out string)) {
+	go func() {
+		out, err := runFilter(context.Background(), args, text)
+		shell.Jobs <- shell.JobFunction{
+			Function: func(string, []interface{}) {
+				if err != nil {
+					InfoBar.Error(err)
+				} else {
+					done(out)
+				}
+				screen.Redraw()
+			},
+		}
+	}()
 }
 
 // TabMoveCmd moves the current tab to a given index (starts at 1). The
@@ -399,18 +884,7 @@ func reloadRuntime(reloadPlugins bool) {
 
 // ReopenCmd reopens the buffer (reload from disk)
 func (h *BufPane) ReopenCmd(args []string) {
-	if h.Buf.Modified() {
-		InfoBar.YNPrompt("Save file before reopen?", func(yes, canceled bool) {
-			if !canceled && yes {
-				h.Save()
-				h.Buf.ReOpen()
-			} else if !canceled {
-				h.Buf.ReOpen()
-			}
-		})
-	} else {
-		h.Buf.ReOpen()
-	}
+	h.Reopen()
 }
 
 func (h *BufPane) openHelp(page string) error {
@@ -484,6 +958,93 @@ func (h *BufPane) HSplitCmd(args []string) {
 	h.HSplitBuf(buf)
 }
 
+// minDiffSplitWidth is the width of the pane below which DiffHeadCmd stacks
+// both versions instead of showing them side by side
+const minDiffSplitWidth = 120
+
+// A diffHead is the version at the Git HEAD of the buffer of another pane,
+// shown by DiffHeadCmd
+type diffHead struct {
+	source *buffer.Buffer
+	// diffgutter is the value of the option in source before the diff was
+	// shown, restored when the split is closed
+	diffgutter bool
+}
+
+// diffHeadActions replace the actions of the same name in the panes showing
+// a HEAD version
+var diffHeadActions = map[string]BufKeyAction{
+	"Escape": (*BufPane).diffHeadClose,
+}
+
+// DiffHeadCmd opens a read-only split with the version of the file at the Git
+// HEAD, and shows the differences between both versions in their gutters
+func (h *BufPane) DiffHeadCmd(args []string) {
+	if h.Buf.Path == "" {
+		InfoBar.Error("The buffer has no file")
+		return
+	}
+
+	dir, name := filepath.Split(h.Buf.AbsPath)
+	head, err := shell.ExecCommand("git", "-C", dir, "show", "HEAD:./"+name)
+	if err != nil {
+		InfoBar.Error("No Git HEAD version of " + h.Buf.GetName())
+		return
+	}
+
+	buf := buffer.NewBufferFromString(head, "", buffer.BTScratch)
+	buf.Type.Readonly = true
+	buf.SetName("HEAD:" + h.Buf.GetName())
+	buf.Settings["filetype"] = h.Buf.FileType()
+	buf.UpdateRules()
+	buf.SetOptionNative("diffgutter", true)
+	buf.SetDiffBase(h.Buf.Bytes())
+
+	diffgutter := h.Buf.Settings["diffgutter"].(bool)
+	h.Buf.SetOptionNative("diffgutter", true)
+	h.Buf.SetDiffBase([]byte(head))
+
+	var p *BufPane
+	if h.GetView().Width < minDiffSplitWidth {
+		p = h.HSplitBuf(buf)
+	} else {
+		p = h.VSplitBuf(buf)
+	}
+	p.diffHead = &diffHead{source: h.Buf, diffgutter: diffgutter}
+}
+
+// refreshDiffHeads updates the differences shown in the HEAD versions of the
+// given buffer after it was modified
+func refreshDiffHeads(b *buffer.Buffer) {
+	for _, t := range Tabs.List {
+		for _, p := range t.Panes {
+			bp, ok := p.(*BufPane)
+			if ok && bp.diffHead != nil && bp.diffHead.source.SharedBuffer == b.SharedBuffer {
+				bp.Buf.SetDiffBase(b.Bytes())
+			}
+		}
+	}
+}
+
+// closeDiffHead restores the diffgutter option of the buffer compared with
+// the HEAD version shown by this pane, when it stops showing it
+func (h *BufPane) closeDiffHead() {
+	if h.diffHead == nil {
+		return
+	}
+	h.diffHead.source.SetOptionNative("diffgutter", h.diffHead.diffgutter)
+	h.diffHead = nil
+}
+
+// diffHeadClose closes the pane showing a HEAD version, unless it is the last
+// pane, since closing it would quit
+func (h *BufPane) diffHeadClose() bool {
+	if len(h.tab.Panes) > 1 || len(Tabs.List) > 1 {
+		h.ForceQuit()
+	}
+	return true
+}
+
 // EvalCmd evaluates a lua expression
 func (h *BufPane) EvalCmd(args []string) {
 	InfoBar.Error("Eval unsupported")
@@ -525,6 +1086,7 @@ func SetGlobalOptionNative(option string, nativeValue interface{}) error {
 	config.GlobalSettings[option] = nativeValue
 	config.ModifiedSettings[option] = true
 	delete(config.VolatileSettings, option)
+	config.ForgetView(option)
 
 	if option == "colorscheme" {
 		// LoadSyntaxFiles()
@@ -744,31 +1306,149 @@ func (h *BufPane) QuitCmd(args []string) {
 	h.Quit()
 }
 
+// SaveAllCmd saves all modified buffers, like the SaveAll action
+func (h *BufPane) SaveAllCmd(args []string) {
+	h.SaveAll()
+}
+
+// ForceQuitCmd closes the current split or tab without asking to save its
+// changes, like the ForceQuit action
+func (h *BufPane) ForceQuitCmd(args []string) {
+	h.ForceQuit()
+}
+
+// CloseOthersCmd closes all the splits and tabs except the current split,
+// asking once before discarding the changes of their modified buffers
+func (h *BufPane) CloseOthersCmd(args []string) {
+	var others []Pane
+	// the buffers also open in the current split are not closed
+	seen := map[*buffer.SharedBuffer]bool{h.Buf.SharedBuffer: true}
+	modified := 0
+	for _, t := range Tabs.List {
+		for _, p := range t.Panes {
+			if p.ID() == h.ID() {
+				continue
+			}
+			others = append(others, p)
+			if bp, ok := p.(*BufPane); ok && !seen[bp.Buf.SharedBuffer] {
+				seen[bp.Buf.SharedBuffer] = true
+				if bp.Buf.Modified() {
+					modified++
+				}
+			}
+		}
+	}
+	if len(others) == 0 {
+		InfoBar.Message("No other splits or tabs to close")
+		return
+	}
+
+	closeOthers := func() {
+		for _, p := range others {
+			p.Close()
+			if t := p.Tab(); t == h.tab {
+				t.GetNode(p.ID()).Unsplit()
+				t.RemovePane(t.GetPane(p.ID()))
+			}
+		}
+		h.tab.Resize()
+		h.tab.SetActive(h.tab.GetPane(h.ID()))
+		for i := len(Tabs.List) - 1; i >= 0; i-- {
+			if t := Tabs.List[i]; t != h.tab {
+				Tabs.RemoveTab(t.Panes[0].ID())
+			}
+		}
+		Tabs.SetActive(0)
+	}
+
+	if modified > 0 {
+		InfoBar.YNPrompt(fmt.Sprintf("Close the other splits and tabs, discarding the changes of %d modified buffers? (y,n)", modified), func(yes, canceled bool) {
+			if !canceled && yes {
+				closeOthers()
+			}
+		})
+	} else {
+		closeOthers()
+	}
+}
+
 // GotoCmd is a command that will send the cursor to a certain
 // position in the buffer
 // For example: `goto line`, or `goto line:col`
 func (h *BufPane) GotoCmd(args []string) {
-	line, col, err := h.parseLineCol(args)
+	loc, err := h.gotoTarget(args)
 	if err != nil {
 		InfoBar.Error(err)
 		return
 	}
 
-	if line < 0 {
-		line = h.Buf.LinesNum() + 1 + line
+	h.RemoveAllMultiCursors()
+	h.GotoLoc(loc)
+}
+
+// gotoTarget returns the location in the buffer given by the arguments of
+// the goto command
+func (h *BufPane) gotoTarget(args []string) (buffer.Loc, error) {
+	if len(args) <= 0 {
+		return buffer.Loc{}, errors.New("Not enough arguments")
 	}
+
+	spec, colSpec := args[0], "0"
+	if i := strings.IndexAny(spec, ":|"); i >= 0 {
+		spec, colSpec = spec[:i], "0"+spec[i:]
+	}
+	_, col, visual, err := h.parseLineCol([]string{colSpec})
+	if err != nil {
+		return buffer.Loc{}, err
+	}
+	line, err := h.gotoLine(spec)
+	if err != nil {
+		return buffer.Loc{}, err
+	}
+
 	line = util.Clamp(line-1, 0, h.Buf.LinesNum()-1)
-	col = util.Clamp(col-1, 0, util.CharacterCount(h.Buf.LineBytes(line)))
+	col = h.lineColToChar(line, col, visual)
+	return buffer.Loc{X: col, Y: line}, nil
+}
 
-	h.RemoveAllMultiCursors()
-	h.GotoLoc(buffer.Loc{col, line})
+// gotoLine returns the line number given to the goto command, which is
+// either absolute, counted from the end of the buffer if it is negative, a
+// percentage of the buffer (50%), relative to the current line (+20) or the
+// last line ($). The result may be out of the buffer
+func (h *BufPane) gotoLine(spec string) (int, error) {
+	n := h.Buf.LinesNum()
+	switch {
+	case spec == "$":
+		return n, nil
+	case strings.HasSuffix(spec, "%"):
+		pct, err := strconv.ParseFloat(strings.TrimSuffix(spec, "%"), 64)
+		if err != nil {
+			return 0, err
+		}
+		return int(math.Round(pct * float64(n) / 100)), nil
+	case strings.HasPrefix(spec, "+"):
+		d, err := strconv.Atoi(spec[1:])
+		if err != nil {
+			return 0, err
+		}
+		return h.Buf.GetActiveCursor().Y + 1 + d, nil
+	}
+
+	line, err := strconv.Atoi(spec)
+	if err != nil {
+		return 0, err
+	}
+	if line < 0 {
+		line = n + 1 + line
+	}
+	return line, nil
 }
 
 // JumpCmd is a command that will send the cursor to a certain relative
 // position in the buffer
 // For example: `jump line`, `jump -line`, or `jump -line:col`
 func (h *BufPane) JumpCmd(args []string) {
-	line, col, err := h.parseLineCol(args)
+	line, col, visual, err := h.parseLineCol(args)
 	if err != nil {
 		InfoBar.Error(err)
 		return
@@ -776,37 +1456,51 @@ func (h *BufPane) JumpCmd(args []string) {
 
 	line = h.Buf.GetActiveCursor().Y + 1 + line
 	line = util.Clamp(line-1, 0, h.Buf.LinesNum()-1)
-	col = util.Clamp(col-1, 0, util.CharacterCount(h.Buf.LineBytes(line)))
+	col = h.lineColToChar(line, col, visual)
 
 	h.RemoveAllMultiCursors()
 	h.GotoLoc(buffer.Loc{col, line})
 }
 
 // parseLineCol is a helper to parse the input of GotoCmd and JumpCmd
-func (h *BufPane) parseLineCol(args []string) (line int, col int, err error) {
+// The column is either separated by ':' for a character index, or by '|'
+// for a display column (which differs when the line contains tabs or
+// wide characters)
+func (h *BufPane) parseLineCol(args []string) (line int, col int, visual bool, err error) {
 	if len(args) <= 0 {
-		return 0, 0, errors.New("Not enough arguments")
+		return 0, 0, false, errors.New("Not enough arguments")
 	}
 
 	line, col = 0, 0
-	if strings.Contains(args[0], ":") {
-		parts := strings.SplitN(args[0], ":", 2)
-		line, err = strconv.Atoi(parts[0])
+	if i := strings.IndexAny(args[0], ":|"); i >= 0 {
+		visual = args[0][i] == '|'
+		line, err = strconv.Atoi(args[0][:i])
 		if err != nil {
-			return 0, 0, err
+			return 0, 0, false, err
 		}
-		col, err = strconv.Atoi(parts[1])
+		col, err = strconv.Atoi(args[0][i+1:])
 		if err != nil {
-			return 0, 0, err
+			return 0, 0, false, err
 		}
 	} else {
 		line, err = strconv.Atoi(args[0])
 		if err != nil {
-			return 0, 0, err
+			return 0, 0, false, err
 		}
 	}
 
-	return line, col, nil
+	return line, col, visual, nil
+}
+
+// lineColToChar converts the 1-based column given to GotoCmd or JumpCmd
+// into a character index on the given line
+func (h *BufPane) lineColToChar(line, col int, visual bool) int {
+	l := h.Buf.LineBytes(line)
+	if visual && col > 0 {
+		tabsize := util.IntOpt(h.Buf.Settings["tabsize"])
+		return util.GetCharPosInLine(l, col-1, tabsize)
+	}
+	return util.Clamp(col-1, 0, util.CharacterCount(l))
 }
 
 // SaveCmd saves the buffer optionally with an argument file name
@@ -818,6 +1512,51 @@ func (h *BufPane) SaveCmd(args []string) {
 	}
 }
 
+// SaveTemplateCmd saves the buffer under a name derived from a template
+// If no template is given, the user is prompted for one
+func (h *BufPane) SaveTemplateCmd(args []string) {
+	if len(args) > 0 {
+		h.saveTemplate(strings.Join(args, " "))
+		return
+	}
+	InfoBar.Prompt("Template: ", "", "Save", nil, func(resp string, canceled bool) {
+		if !canceled && resp != "" {
+			h.saveTemplate(resp)
+		}
+	})
+}
+
+// saveTemplate expands the given file name template and saves the buffer
+// to the resulting path once the user confirmed it
+func (h *BufPane) saveTemplate(tmpl string) {
+	tmpl, err := util.ReplaceHome(tmpl)
+	if err != nil {
+		InfoBar.Error(err)
+		return
+	}
+
+	base := "untitled"
+	if h.Buf.Path != "" {
+		base = filepath.Base(h.Buf.Path)
+		base = strings.TrimSuffix(base, filepath.Ext(base))
+	}
+	exists := func(path string) bool {
+		_, err := os.Stat(path)
+		return err == nil
+	}
+	filename := util.ExpandTemplate(tmpl, base, time.Now(), exists)
+
+	prompt := fmt.Sprintf("Save to %s? (y,n)", filename)
+	if exists(filename) {
+		prompt = fmt.Sprintf("The file %s already exists, would you like to overwrite? (y,n)", filename)
+	}
+	InfoBar.YNPrompt(prompt, func(yes, canceled bool) {
+		if yes && !canceled {
+			h.saveBufToFile(filename, "SaveAs", nil)
+		}
+	})
+}
+
 // ReplaceCmd runs search and replace
 func (h *BufPane) ReplaceCmd(args []string) {
 	if len(args) < 2 || len(args) > 4 {
@@ -825,6 +1564,10 @@ func (h *BufPane) ReplaceCmd(args []string) {
 		InfoBar.Error("Invalid replace statement: " + strings.Join(args, " "))
 		return
 	}
+	if h.Buf.Type.Readonly {
+		InfoBar.Error("Cannot replace in a read-only buffer")
+		return
+	}
 
 	all := false
 	noRegex := false
@@ -909,8 +1652,15 @@ func (h *BufPane) ReplaceCmd(args []string) {
 			h.Buf.LastSearchRegex = true
 			h.Buf.HighlightSearch = h.Buf.Settings["hlsearch"].(bool)
 
-			InfoBar.YNPrompt("Perform replacement (y,n,esc)", func(yes, canceled bool) {
-				if !canceled && yes {
+			InfoBar.YNAllPrompt("Perform replacement (y,n,a,q)", func(yes, all, canceled bool) {
+				if !canceled && all {
+					n, _ := h.Buf.ReplaceRegex(locs[0], end, regex, replace, !noRegex)
+					nreplaced += n
+					h.Cursor.ResetSelection()
+					h.Buf.RelocateCursors()
+					InfoBar.Message(fmt.Sprintf("Replaced %d occurrences of %s", nreplaced, search))
+					return
+				} else if !canceled && yes {
 					_, nrunes := h.Buf.ReplaceRegex(locs[0], locs[1], regex, replace, !noRegex)
 
 					searchLoc = locs[0]
@@ -1045,6 +1795,8 @@ func (h *BufPane) HandleCommand(input string) {
 
 	if _, ok := commands[inputCmd]; !ok {
 		InfoBar.Error("Unknown command ", inputCmd)
+	} else if IsDisabled(inputCmd) {
+		InfoBar.Error("Command ", inputCmd, " is disabled")
 	} else {
 		WriteLog("> " + input + "\n")
 		commands[inputCmd].action(h, args[1:])
@@ -0,0 +1,178 @@
+package action
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	lua "github.com/yuin/gopher-lua"
+	"github.com/zyedidia/micro/v2/internal/buffer"
+	"github.com/zyedidia/micro/v2/internal/config"
+	"github.com/zyedidia/micro/v2/internal/display"
+	ulua "github.com/zyedidia/micro/v2/internal/lua"
+	"github.com/zyedidia/micro/v2/internal/screen"
+)
+
+func init() {
+	ulua.L = lua.NewState()
+}
+
+func TestIsDisabled(t *testing.T) {
+	settings := config.GlobalSettings
+	config.GlobalSettings = config.DefaultGlobalSettings()
+	defer func() { config.GlobalSettings = settings }()
+
+	assert.False(t, IsDisabled("quit"))
+
+	// as read from settings.json
+	config.GlobalSettings["disabledcommands"] = []interface{}{"quit", "Quit"}
+	assert.True(t, IsDisabled("quit"))
+	assert.True(t, IsDisabled("Quit"))
+	assert.False(t, IsDisabled("save"))
+	assert.False(t, IsDisabled("QuitAll"))
+
+	// the default value is a list of strings
+	config.GlobalSettings["disabledcommands"] = []string{"NextSplit"}
+	assert.True(t, IsDisabled("NextSplit"))
+	assert.False(t, IsDisabled("quit"))
+}
+
+func TestGotoLine(t *testing.T) {
+	settings := config.GlobalSettings
+	config.GlobalSettings = config.DefaultGlobalSettings()
+	defer func() { config.GlobalSettings = settings }()
+
+	b := buffer.NewBufferFromString(strings.Repeat("line\n", 9), "", buffer.BTDefault)
+	defer b.Close()
+	h := newBufPane(b, nil, nil)
+	h.Cursor.GotoLoc(buffer.Loc{X: 0, Y: 2})
+
+	line := func(spec string) int {
+		l, err := h.gotoLine(spec)
+		assert.Nil(t, err)
+		return l
+	}
+	assert.Equal(t, 10, line("$"))
+	assert.Equal(t, 5, line("50%"))
+	assert.Equal(t, 3, line("25%"))
+	assert.Equal(t, 2, line("15%"))
+	assert.Equal(t, 8, line("+5"))
+	assert.Equal(t, 4, line("4"))
+	assert.Equal(t, 9, line("-2"))
+	_, err := h.gotoLine("x")
+	assert.NotNil(t, err)
+
+	// targets out of the buffer are clamped
+	target := func(spec string) buffer.Loc {
+		loc, err := h.gotoTarget([]string{spec})
+		assert.Nil(t, err)
+		return loc
+	}
+	assert.Equal(t, buffer.Loc{X: 0, Y: 9}, target("$"))
+	assert.Equal(t, buffer.Loc{X: 0, Y: 9}, target("+50"))
+	assert.Equal(t, buffer.Loc{X: 0, Y: 9}, target("200%"))
+	assert.Equal(t, buffer.Loc{X: 0, Y: 0}, target("-50"))
+	assert.Equal(t, buffer.Loc{X: 4, Y: 3}, target("4:10"))
+}
+
+func TestSnippet(t *testing.T) {
+	settings := config.GlobalSettings
+	config.GlobalSettings = config.DefaultGlobalSettings()
+	defer func() { config.GlobalSettings = settings }()
+	configDir := config.ConfigDir
+	config.ConfigDir = t.TempDir()
+	defer func() { config.ConfigDir = configDir }()
+	// the Undo action shows a message
+	if _, err := screen.InitSimScreen(); err != nil {
+		t.Fatal(err)
+	}
+	defer screen.Screen.Fini()
+	InfoBar = NewInfoBar()
+	defer func() { InfoBar = nil }()
+
+	snippets := `{"*": {"fn": "func ${1:name}(${2}) {\n\t$0\n}"}}`
+	err := os.WriteFile(filepath.Join(config.ConfigDir, "snippets.json"), []byte(snippets), 0644)
+	assert.Nil(t, err)
+
+	expand := func() *BufPane {
+		b := buffer.NewBufferFromString("\tfn", "", buffer.BTDefault)
+		h := newBufPane(b, display.NewBufWindow(0, 0, 80, 24, b), nil)
+		h.Cursor.GotoLoc(b.End())
+		assert.True(t, h.Snippet())
+		return h
+	}
+
+	// the snippet is indented like its line, and its first tab stop selected
+	h := expand()
+	assert.Equal(t, "\tfunc name() {\n\t\t\n\t}", string(h.Buf.Bytes()))
+	assert.Equal(t, "name", string(h.Cursor.GetSelection()))
+
+	// the next tab stops are shifted by the text typed in the current one
+	for _, r := range "run" {
+		h.DoRuneInsert(r)
+	}
+	assert.True(t, h.Snippet())
+	assert.Equal(t, buffer.Loc{X: 10, Y: 0}, h.Cursor.Loc)
+	assert.False(t, h.Cursor.HasSelection())
+	h.DoRuneInsert('x')
+	assert.True(t, h.Snippet())
+	assert.Equal(t, "\tfunc run(x) {\n\t\t\n\t}", string(h.Buf.Bytes()))
+	assert.Equal(t, buffer.Loc{X: 2, Y: 1}, h.Cursor.Loc)
+	// $0 is the last stop
+	assert.Nil(t, h.snippet)
+	h.Buf.Close()
+
+	// moving out of the tab stop leaves the snippet
+	h = expand()
+	h.Cursor.ResetSelection()
+	h.Cursor.GotoLoc(buffer.Loc{X: 0, Y: 1})
+	assert.False(t, h.Snippet())
+	assert.Nil(t, h.snippet)
+	h.Buf.Close()
+
+	// and so do Escape and Undo
+	h = expand()
+	h.Escape()
+	assert.Nil(t, h.snippet)
+	h.Buf.Close()
+
+	h = expand()
+	h.Undo()
+	assert.Nil(t, h.snippet)
+	h.Buf.Close()
+}
+
+func TestLineColToChar(t *testing.T) {
+	settings := config.GlobalSettings
+	config.GlobalSettings = config.DefaultGlobalSettings()
+	defer func() { config.GlobalSettings = settings }()
+
+	b := buffer.NewBufferFromString("\tab\n日本x", "", buffer.BTDefault)
+	defer b.Close()
+	b.SetOptionNative("tabsize", float64(4))
+	h := newBufPane(b, nil, nil)
+
+	// visual columns count the width of tabs and wide characters
+	assert.Equal(t, 0, h.lineColToChar(0, 1, true))
+	assert.Equal(t, 0, h.lineColToChar(0, 3, true))
+	assert.Equal(t, 1, h.lineColToChar(0, 5, true))
+	assert.Equal(t, 2, h.lineColToChar(0, 6, true))
+	assert.Equal(t, 0, h.lineColToChar(1, 2, true))
+	assert.Equal(t, 1, h.lineColToChar(1, 3, true))
+	assert.Equal(t, 2, h.lineColToChar(1, 5, true))
+	assert.Equal(t, 3, h.lineColToChar(1, 99, true))
+
+	// other columns count characters
+	assert.Equal(t, 1, h.lineColToChar(1, 2, false))
+	assert.Equal(t, 3, h.lineColToChar(1, 99, false))
+	assert.Equal(t, 0, h.lineColToChar(1, 0, true))
+
+	loc, err := h.gotoTarget([]string{"2|5"})
+	assert.Nil(t, err)
+	assert.Equal(t, buffer.Loc{X: 2, Y: 1}, loc)
+	loc, err = h.gotoTarget([]string{"1:2"})
+	assert.Nil(t, err)
+	assert.Equal(t, buffer.Loc{X: 1, Y: 0}, loc)
+}
@@ -36,7 +36,7 @@ var bufdefaults = map[string]string{
 	"OldBackspace":   "Backspace",
 	"Alt-CtrlH":      "DeleteWordLeft",
 	"Alt-Backspace":  "DeleteWordLeft",
-	"Tab":            "Autocomplete|IndentSelection|InsertTab",
+	"Tab":            "Snippet|Autocomplete|IndentSelection|InsertTab",
 	"Backtab":        "CycleAutocompleteBack|OutdentSelection|OutdentLine",
 	"Ctrl-o":         "OpenFile",
 	"Ctrl-s":         "Save",
@@ -46,6 +46,8 @@ var bufdefaults = map[string]string{
 	"Ctrl-p":         "FindPrevious",
 	"Alt-[":          "DiffPrevious|CursorStart",
 	"Alt-]":          "DiffNext|CursorEnd",
+	"Alt-o":          "LocationBack",
+	"Alt-i":          "LocationForward",
 	"Ctrl-z":         "Undo",
 	"Ctrl-y":         "Redo",
 	"Ctrl-c":         "CopyLine|Copy",
@@ -76,6 +78,7 @@ var bufdefaults = map[string]string{
 	"Ctrl-w":         "NextSplit",
 	"Ctrl-u":         "ToggleMacro",
 	"Ctrl-j":         "PlayMacro",
+	"CtrlRightSq":    "JumpToMatchingBrace",
 	"Insert":         "ToggleOverwriteMode",
 
 	// Emacs-style keybindings
@@ -168,6 +171,7 @@ var infodefaults = map[string]string{
 	"Ctrl-n":         "HistoryDown",
 	"Ctrl-p":         "HistoryUp",
 	"Ctrl-u":         "SelectToStart",
+	"Alt-r":          "ToggleSearchRegex",
 
 	// Emacs-style keybindings
 	"Alt-f": "WordRight",
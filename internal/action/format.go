@@ -0,0 +1,114 @@
+package action
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/zyedidia/micro/v2/internal/buffer"
+	"github.com/zyedidia/micro/v2/internal/util"
+)
+
+// formatTimeout is how long the formatonsave option waits for the formatter
+// before saving the buffer unformatted
+const formatTimeout = 5 * time.Second
+
+// formatters are, by filetype, the commands formatting their standard input,
+// by order of preference. %f is replaced by the name of the file
+var formatters = map[string][][]string{
+	"go":         {{"goimports"}, {"gofmt"}},
+	"python":     {{"black", "-q", "-"}},
+	"javascript": {{"prettier", "--stdin-filepath", "%f"}},
+	"typescript": {{"prettier", "--stdin-filepath", "%f"}},
+	"css":        {{"prettier", "--stdin-filepath", "%f"}},
+	"html":       {{"prettier", "--stdin-filepath", "%f"}},
+}
+
+// formatter returns the first installed formatter of the filetype of the
+// buffer, or an error message if there is none
+func (h *BufPane) formatter() ([]string, string) {
+	ft := h.Buf.FileType()
+	cmds, ok := formatters[ft]
+	if !ok {
+		return nil, "No formatter for " + ft + " files"
+	}
+
+	var names []string
+	for _, c := range cmds {
+		if _, err := exec.LookPath(c[0]); err != nil {
+			names = append(names, c[0])
+			continue
+		}
+		args := make([]string, len(c))
+		for i, a := range c {
+			args[i] = strings.ReplaceAll(a, "%f", h.Buf.GetName())
+		}
+		return args, ""
+	}
+	return nil, "No formatter installed for " + ft + " files, install " + strings.Join(names, " or ")
+}
+
+// setFormatted replaces the whole buffer with its formatted text, keeping the
+// cursor on the same line if possible
+func (h *BufPane) setFormatted(text string) {
+	if string(h.Buf.Bytes()) == text {
+		return
+	}
+	y := h.Cursor.Y
+	h.Cursor.Deselect(true)
+	h.Buf.Replace(h.Buf.Start(), h.Buf.End(), text)
+	h.Cursor.GotoLoc(buffer.Loc{X: 0, Y: util.Min(y, h.Buf.LinesNum()-1)})
+	h.Relocate()
+}
+
+// FormatCmd formats the buffer with the formatter of its filetype, which runs
+// in the background. The buffer is left untouched if the formatter fails or
+// if the buffer changes meanwhile
+func (h *BufPane) FormatCmd(args []string) {
+	if h.Buf.Type.Readonly {
+		InfoBar.Error("Cannot modify a read-only buffer")
+		return
+	}
+	cmd, msg := h.formatter()
+	if cmd == nil {
+		InfoBar.Error(msg)
+		return
+	}
+
+	text := string(h.Buf.Bytes())
+	filterText(cmd, text, func(out string) {
+		if string(h.Buf.Bytes()) != text {
+			InfoBar.Error("The buffer changed while running ", cmd[0])
+			return
+		}
+		h.setFormatted(out)
+		InfoBar.Message("Formatted with " + cmd[0])
+	})
+}
+
+// formatBeforeSave formats the buffer if the formatonsave option is on. The
+// formatter runs synchronously, up to formatTimeout, so that the formatted
+// text is saved
+func (h *BufPane) formatBeforeSave() error {
+	if !h.Buf.Settings["formatonsave"].(bool) || h.Buf.Type.Readonly {
+		return nil
+	}
+	if _, ok := formatters[h.Buf.FileType()]; !ok {
+		return nil
+	}
+	cmd, msg := h.formatter()
+	if cmd == nil {
+		return errors.New(msg)
+	}
+	// the save waits for the formatter, which must not hang the editor
+	ctx, cancel := context.WithTimeout(context.Background(), formatTimeout)
+	defer cancel()
+	out, err := runFilter(ctx, cmd, string(h.Buf.Bytes()))
+	if err != nil {
+		return err
+	}
+	h.setFormatted(out)
+	return nil
+}
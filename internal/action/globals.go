@@ -8,10 +8,19 @@ var InfoBar *InfoPane
 // LogBufPane is a global log buffer.
 var LogBufPane *BufPane
 
+// locHistory stores the significant locations visited across all buffers
+var locHistory buffer.LocHistory
+
+// traversingHistory is set while moving through the location history so
+// that the resulting jumps are not recorded themselves
+var traversingHistory bool
+
 // InitGlobals initializes the log buffer and the info bar
 func InitGlobals() {
 	InfoBar = NewInfoBar()
 	buffer.LogBuf = buffer.NewBufferFromString("", "Log", buffer.BTLog)
+	buffer.OnContentChanged(refreshOutlines)
+	buffer.OnContentChanged(refreshDiffHeads)
 }
 
 // GetInfoBar returns the infobar pane
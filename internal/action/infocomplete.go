@@ -3,6 +3,7 @@ package action
 import (
 	"bytes"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/zyedidia/micro/v2/internal/buffer"
@@ -19,15 +20,44 @@ import (
 func CommandComplete(b *buffer.Buffer) ([]string, []string) {
 	c := b.GetActiveCursor()
 	input, argstart := b.GetArg()
+	if input == "" {
+		return nil, nil
+	}
+	lower := strings.ToLower(input)
 
-	var suggestions []string
+	// the commands starting with the input come first, then the ones that
+	// only fuzzy match it
+	var suggestions, fuzzy []string
 	for cmd := range commands {
-		if strings.HasPrefix(cmd, input) {
+		if IsDisabled(cmd) {
+			continue
+		}
+		if strings.HasPrefix(cmd, lower) {
 			suggestions = append(suggestions, cmd)
+		} else if util.FuzzyMatch(input, cmd) {
+			fuzzy = append(fuzzy, cmd)
 		}
 	}
 
+	if len(suggestions) == 0 && len(fuzzy) == 0 {
+		return nil, nil
+	}
 	sort.Strings(suggestions)
+
+	// the input is first completed up to the common prefix of the commands
+	// starting with it, and the next completion cycles through them
+	if len(suggestions) > 0 {
+		if prefix := util.CommonPrefix(suggestions); len(prefix) > len(input) {
+			return b.ReplaceArg([]string{prefix})
+		}
+	}
+
+	if len(fuzzy) > 0 || input != lower {
+		sort.Strings(fuzzy)
+		suggestions = append(suggestions, fuzzy...)
+		return b.ReplaceArg(suggestions)
+	}
+
 	completions := make([]string, len(suggestions))
 	for i := range suggestions {
 		completions[i] = util.SliceEndStr(suggestions[i], c.X-argstart)
@@ -69,6 +99,14 @@ func colorschemeComplete(input string) (string, []string) {
 			suggestions = append(suggestions, f.Name())
 		}
 	}
+	if len(suggestions) == 0 {
+		// e.g. "gtc" completes to "gruvbox-tc"
+		for _, f := range files {
+			if util.FuzzyMatch(input, f.Name()) {
+				suggestions = append(suggestions, f.Name())
+			}
+		}
+	}
 
 	var chosen string
 	if len(suggestions) == 1 {
@@ -251,6 +289,10 @@ func OptionValueComplete(b *buffer.Buffer) ([]string, []string) {
 	}
 	sort.Strings(suggestions)
 
+	if len(suggestions) > 0 && !strings.HasPrefix(suggestions[0], input) {
+		return b.ReplaceArg(suggestions)
+	}
+
 	completions := make([]string, len(suggestions))
 	for i := range suggestions {
 		completions[i] = util.SliceEndStr(suggestions[i], c.X-argstart)
@@ -278,6 +320,175 @@ func PluginCmdComplete(b *buffer.Buffer) ([]string, []string) {
 	return completions, suggestions
 }
 
+// StateComplete autocompletes the state command
+func StateComplete(b *buffer.Buffer) ([]string, []string) {
+	c := b.GetActiveCursor()
+	input, argstart := b.GetArg()
+
+	var suggestions []string
+	for _, cmd := range StateCmds {
+		if strings.HasPrefix(cmd, input) {
+			suggestions = append(suggestions, cmd)
+		}
+	}
+
+	sort.Strings(suggestions)
+	completions := make([]string, len(suggestions))
+	for i := range suggestions {
+		completions[i] = util.SliceEndStr(suggestions[i], c.X-argstart)
+	}
+	return completions, suggestions
+}
+
+// RecentComplete autocompletes the recent command with the recent files whose
+// full path fuzzy matches the argument, the most recent first
+func RecentComplete(b *buffer.Buffer) ([]string, []string) {
+	input, _ := b.GetArg()
+
+	var suggestions []string
+	for _, f := range config.RecentFiles() {
+		f = util.CollapseHome(f)
+		if util.FuzzyMatch(input, f) {
+			suggestions = append(suggestions, f)
+		}
+	}
+	if len(suggestions) == 0 {
+		return nil, nil
+	}
+
+	// the suggestions don't necessarily start with the argument, so they
+	// replace it
+	return b.ReplaceArg(suggestions)
+}
+
+// ProjectFileComplete autocompletes the findfile command with the files of
+// the current directory tree whose path fuzzy matches the argument
+func ProjectFileComplete(b *buffer.Buffer) ([]string, []string) {
+	input, _ := b.GetArg()
+
+	suggestions, done := indexProject(false).Match(input)
+	if len(suggestions) == 0 {
+		if !done {
+			InfoBar.Message("Still indexing files...")
+		}
+		return nil, nil
+	}
+
+	return b.ReplaceArg(suggestions)
+}
+
+// BookmarkComplete autocompletes the bookmarks command with the bookmarked
+// lines of the current buffer whose number starts with the argument or whose
+// text fuzzy matches it
+func BookmarkComplete(b *buffer.Buffer) ([]string, []string) {
+	input, _ := b.GetArg()
+
+	h := MainTab().CurPane()
+	if h == nil {
+		return nil, nil
+	}
+
+	var suggestions []string
+	for _, m := range h.Buf.Bookmarks() {
+		line := strconv.Itoa(m + 1)
+		if strings.HasPrefix(line, input) || util.FuzzyMatch(input, string(h.Buf.LineBytes(m))) {
+			suggestions = append(suggestions, line)
+		}
+	}
+	if len(suggestions) == 0 {
+		return nil, nil
+	}
+
+	// the argument may be some text of the line, it is replaced by the line
+	// number
+	return b.ReplaceArg(suggestions)
+}
+
+// SymbolComplete autocompletes the symbol command with the symbols of the
+// current buffer whose name fuzzy matches the argument, followed by their
+// line number
+func SymbolComplete(b *buffer.Buffer) ([]string, []string) {
+	input, _ := b.GetArg()
+
+	h := MainTab().CurPane()
+	if h == nil {
+		return nil, nil
+	}
+
+	var suggestions []string
+	for _, s := range h.Buf.Symbols() {
+		if util.FuzzyMatch(input, s.Name) {
+			suggestions = append(suggestions, s.Name+":"+strconv.Itoa(s.Line+1))
+		}
+	}
+	if len(suggestions) == 0 {
+		return nil, nil
+	}
+
+	return b.ReplaceArg(suggestions)
+}
+
+// TimestampComplete autocompletes the named formats of the timestamp command
+func TimestampComplete(b *buffer.Buffer) ([]string, []string) {
+	c := b.GetActiveCursor()
+	input, argstart := b.GetArg()
+
+	var suggestions []string
+	for name := range util.TimestampFormats {
+		if strings.HasPrefix(name, input) {
+			suggestions = append(suggestions, name)
+		}
+	}
+	if strings.HasPrefix("unix", input) {
+		suggestions = append(suggestions, "unix")
+	}
+
+	sort.Strings(suggestions)
+	completions := make([]string, len(suggestions))
+	for i := range suggestions {
+		completions[i] = util.SliceEndStr(suggestions[i], c.X-argstart)
+	}
+	return completions, suggestions
+}
+
+// EncodingComplete autocompletes the encode and decode commands
+func EncodingComplete(b *buffer.Buffer) ([]string, []string) {
+	c := b.GetActiveCursor()
+	input, argstart := b.GetArg()
+
+	var suggestions []string
+	for _, enc := range util.Encodings {
+		if strings.HasPrefix(enc, input) {
+			suggestions = append(suggestions, enc)
+		}
+	}
+
+	completions := make([]string, len(suggestions))
+	for i := range suggestions {
+		completions[i] = util.SliceEndStr(suggestions[i], c.X-argstart)
+	}
+	return completions, suggestions
+}
+
+// PrettyComplete autocompletes the pretty and minify commands
+func PrettyComplete(b *buffer.Buffer) ([]string, []string) {
+	c := b.GetActiveCursor()
+	input, argstart := b.GetArg()
+
+	var suggestions []string
+	for _, lang := range util.PrettyLanguages {
+		if strings.HasPrefix(lang, input) {
+			suggestions = append(suggestions, lang)
+		}
+	}
+
+	completions := make([]string, len(suggestions))
+	for i := range suggestions {
+		completions[i] = util.SliceEndStr(suggestions[i], c.X-argstart)
+	}
+	return completions, suggestions
+}
+
 // PluginComplete completes values for the plugin command
 func PluginComplete(b *buffer.Buffer) ([]string, []string) {
 	c := b.GetActiveCursor()
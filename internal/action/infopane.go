@@ -101,6 +101,16 @@ func (h *InfoPane) HandleEvent(event tcell.Event) {
 			} else if (e.Rune() == 'n' || e.Rune() == 'N') && hasYN {
 				h.YNResp = false
 				h.DonePrompt(false)
+			} else if (e.Rune() == 'a' || e.Rune() == 'A') && h.HasYNAll {
+				h.YNResp = true
+				h.YNAll = true
+				h.DonePrompt(false)
+			} else if (e.Rune() == 's' || e.Rune() == 'S') && h.HasYNSave {
+				h.YNResp = true
+				h.YNSave = true
+				h.DonePrompt(false)
+			} else if (e.Rune() == 'q' || e.Rune() == 'Q') && h.HasYNAll {
+				h.DonePrompt(true)
 			}
 		}
 		if e.Key() == tcell.KeyRune && !done && !hasYN {
@@ -214,6 +224,22 @@ func (h *InfoPane) AbortCommand() {
 	h.DonePrompt(true)
 }
 
+// ToggleSearchRegex switches the find prompt between regex and literal search
+func (h *InfoPane) ToggleSearchRegex() {
+	if !h.HasPrompt || h.PromptType != "Find" {
+		return
+	}
+	bp := MainTab().CurPane()
+	if bp == nil {
+		return
+	}
+	bp.searchRegex = !bp.searchRegex
+	h.Msg = bp.findPrompt("")
+	if h.EventCallback != nil {
+		h.EventCallback(string(h.LineBytes(0)))
+	}
+}
+
 // InfoKeyActions contains the list of all possible key actions the infopane could execute
 var InfoKeyActions = map[string]InfoKeyAction{
 	"HistoryUp":         (*InfoPane).HistoryUp,
@@ -223,4 +249,5 @@ var InfoKeyActions = map[string]InfoKeyAction{
 	"CommandComplete":   (*InfoPane).CommandComplete,
 	"ExecuteCommand":    (*InfoPane).ExecuteCommand,
 	"AbortCommand":      (*InfoPane).AbortCommand,
+	"ToggleSearchRegex": (*InfoPane).ToggleSearchRegex,
 }
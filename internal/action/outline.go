@@ -0,0 +1,153 @@
+package action
+
+import (
+	"strings"
+
+	"github.com/zyedidia/micro/v2/internal/buffer"
+	"github.com/zyedidia/micro/v2/internal/util"
+)
+
+// An outline is a read-only split listing the symbols of the buffer of
+// another pane, indented by nesting
+type outline struct {
+	source *BufPane
+	// buf is the buffer the symbols were read from, which is no longer
+	// the buffer of source once it opens another one
+	buf     *buffer.Buffer
+	symbols []buffer.Symbol
+}
+
+// outlineActions replace the actions of the same name in outline panes
+var outlineActions = map[string]BufKeyAction{
+	"InsertNewline": (*BufPane).outlineJump,
+	"Escape":        (*BufPane).outlineClose,
+}
+
+// outlineText returns the content of the outline of the given symbols, one
+// line per symbol
+func outlineText(symbols []buffer.Symbol) string {
+	lines := make([]string, len(symbols))
+	for i, s := range symbols {
+		lines[i] = strings.Repeat("  ", s.Depth) + s.Name
+	}
+	return strings.Join(lines, "\n")
+}
+
+// OutlineCmd opens a split with the outline of the buffer. If the tab
+// already has an outline, it is updated to show the current buffer instead
+func (h *BufPane) OutlineCmd(args []string) {
+	if h.outline != nil {
+		return
+	}
+	symbols := h.Buf.Symbols()
+	if len(symbols) == 0 {
+		InfoBar.Message("No symbols in " + h.Buf.GetName())
+		return
+	}
+
+	for _, p := range h.tab.Panes {
+		if bp, ok := p.(*BufPane); ok && bp.outline != nil {
+			bp.outline.source = h
+			bp.showOutline(symbols)
+			bp.GotoLoc(bp.Buf.Start())
+			return
+		}
+	}
+
+	buf := buffer.NewBufferFromString(outlineText(symbols), "", buffer.BTScratch)
+	buf.Type.Readonly = true
+	buf.SetName("Outline:" + h.Buf.GetName())
+	h.VSplitBuf(buf).outline = &outline{source: h, buf: h.Buf, symbols: symbols}
+}
+
+// showOutline replaces the content of the outline pane with the given
+// symbols of its source buffer
+func (h *BufPane) showOutline(symbols []buffer.Symbol) {
+	h.outline.buf = h.outline.source.Buf
+	h.outline.symbols = symbols
+	h.Buf.Type.Readonly = false
+	h.Buf.Replace(h.Buf.Start(), h.Buf.End(), outlineText(symbols))
+	h.Buf.Type.Readonly = true
+	h.Buf.SetName("Outline:" + h.outline.source.Buf.GetName())
+}
+
+// refreshOutlines updates the outlines of the given buffer after it was
+// modified
+func refreshOutlines(b *buffer.Buffer) {
+	for _, t := range Tabs.List {
+		for _, p := range t.Panes {
+			bp, ok := p.(*BufPane)
+			if !ok || bp.outline == nil {
+				continue
+			}
+			if bp.outline.source.Buf.SharedBuffer == b.SharedBuffer {
+				y := bp.Cursor.Y
+				bp.showOutline(b.Symbols())
+				bp.Cursor.ResetSelection()
+				bp.Cursor.GotoLoc(buffer.Loc{X: 0, Y: util.Clamp(y, 0, bp.Buf.LinesNum()-1)})
+			} else {
+				bp.syncOutline()
+			}
+		}
+	}
+}
+
+// syncOutlines updates the outlines whose source pane opened another
+// buffer since they were built
+func syncOutlines() {
+	for _, t := range Tabs.List {
+		for _, p := range t.Panes {
+			if bp, ok := p.(*BufPane); ok && bp.outline != nil {
+				bp.syncOutline()
+			}
+		}
+	}
+}
+
+// syncOutline rebuilds the outline from the buffer of its source pane if
+// it was built from another buffer, and returns whether it did
+func (h *BufPane) syncOutline() bool {
+	o := h.outline
+	if o.source.Buf == o.buf {
+		return false
+	}
+	h.showOutline(o.source.Buf.Symbols())
+	h.Cursor.ResetSelection()
+	h.GotoLoc(h.Buf.Start())
+	return true
+}
+
+// outlineJump moves the cursor of the outlined pane to the symbol of the
+// current line of the outline, and focuses it
+func (h *BufPane) outlineJump() bool {
+	o := h.outline
+	if h.syncOutline() {
+		InfoBar.Message("The outline now shows " + o.source.Buf.GetName())
+		return true
+	}
+	y := h.Cursor.Y
+	if y < 0 || y >= len(o.symbols) {
+		return false
+	}
+	for i, t := range Tabs.List {
+		for j, p := range t.Panes {
+			if p == o.source {
+				Tabs.SetActive(i)
+				t.SetActive(j)
+				o.source.GotoLoc(buffer.Loc{X: 0, Y: o.symbols[y].Line})
+				return true
+			}
+		}
+	}
+	InfoBar.Error("The outlined buffer is closed")
+	return false
+}
+
+// outlineClose closes the outline pane, unless it is the last pane, since
+// closing it would quit
+func (h *BufPane) outlineClose() bool {
+	if len(h.tab.Panes) > 1 || len(Tabs.List) > 1 {
+		h.ForceQuit()
+	}
+	return true
+}
@@ -0,0 +1,78 @@
+package action
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/zyedidia/micro/v2/internal/screen"
+	"github.com/zyedidia/micro/v2/internal/shell"
+	"github.com/zyedidia/micro/v2/internal/util"
+)
+
+// maxProjectFiles is the number of files after which indexing a project stops
+const maxProjectFiles = 20000
+
+// A fileIndex lists the files of a project directory. It is filled in the
+// background so that big projects don't block the editor
+type fileIndex struct {
+	sync.Mutex
+	root  string
+	files []string
+	done  bool
+}
+
+var projectIndex *fileIndex
+
+// indexProject returns the index of the current directory, and starts
+// indexing it if this has not been done yet, if the directory changed or if
+// refresh is true
+func indexProject(refresh bool) *fileIndex {
+	wd, err := os.Getwd()
+	if err != nil {
+		return &fileIndex{done: true}
+	}
+	if !refresh && projectIndex != nil && projectIndex.root == wd {
+		return projectIndex
+	}
+
+	idx := &fileIndex{root: wd}
+	projectIndex = idx
+	go func() {
+		err := util.WalkProject(wd, maxProjectFiles, func(rel string) {
+			idx.Lock()
+			idx.files = append(idx.files, rel)
+			idx.Unlock()
+		})
+		idx.Lock()
+		idx.done = true
+		n := len(idx.files)
+		idx.Unlock()
+
+		// report on the main loop
+		shell.Jobs <- shell.JobFunction{
+			Function: func(string, []interface{}) {
+				if err == util.ErrWalkLimit {
+					InfoBar.Message(fmt.Sprintf("Indexed the first %d files of %s", n, wd))
+				}
+				screen.Redraw()
+			},
+		}
+	}()
+	return idx
+}
+
+// Match returns the indexed files whose path fuzzy matches the given pattern,
+// and whether the indexing is finished
+func (idx *fileIndex) Match(pattern string) ([]string, bool) {
+	idx.Lock()
+	defer idx.Unlock()
+
+	var matches []string
+	for _, f := range idx.files {
+		if util.FuzzyMatch(pattern, f) {
+			matches = append(matches, f)
+		}
+	}
+	return matches, idx.done
+}
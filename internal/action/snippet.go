@@ -0,0 +1,129 @@
+package action
+
+import (
+	"strings"
+
+	"github.com/zyedidia/micro/v2/internal/buffer"
+	"github.com/zyedidia/micro/v2/internal/config"
+	"github.com/zyedidia/micro/v2/internal/util"
+)
+
+// A snippetState tracks the tab stops of an expanded snippet
+type snippetState struct {
+	// start is where the snippet was inserted
+	start buffer.Loc
+	// stops are the remaining tab stops, relative to start, the current one
+	// first
+	stops []util.SnippetStop
+	// size is the size of the buffer when the current tab stop was selected,
+	// to know how much the text typed in it moved the following ones
+	size int
+}
+
+func bufferSize(b *buffer.Buffer) int {
+	return b.Start().Diff(b.End(), b)
+}
+
+// Snippet expands the snippet named by the word before the cursor, or moves
+// to the next tab stop of the snippet being filled in. It fails if there is
+// nothing to do, so that the next action of a chain (e.g. Autocomplete) runs
+func (h *BufPane) Snippet() bool {
+	if h.snippet != nil {
+		if h.inSnippetStop() {
+			return h.nextSnippetStop()
+		}
+		h.snippet = nil
+	}
+	if h.Buf.Type.Readonly || h.Cursor.HasSelection() || h.Buf.NumCursors() > 1 {
+		return false
+	}
+
+	c := h.Cursor.Loc
+	line := []rune(string(h.Buf.LineBytes(c.Y)))
+	x := c.X
+	for x > 0 && util.IsWordChar(line[x-1]) {
+		x--
+	}
+	if x == c.X {
+		return false
+	}
+
+	snippets, err := config.Snippets(h.Buf.FileType())
+	if err != nil {
+		InfoBar.Error(err)
+		return false
+	}
+	body, ok := snippets[string(line[x:c.X])]
+	if !ok {
+		return false
+	}
+
+	// the lines of the snippet are indented like the current line, and its
+	// tabs follow the indentation settings of the buffer
+	ws := string(util.GetLeadingWhitespace(h.Buf.LineBytes(c.Y)))
+	body = strings.ReplaceAll(body, "\t", h.Buf.IndentString(util.IntOpt(h.Buf.Settings["tabsize"])))
+	body = strings.ReplaceAll(body, "\n", "\n"+ws)
+	text, stops := util.ParseSnippet(body)
+
+	start := buffer.Loc{X: x, Y: c.Y}
+	h.Buf.Replace(start, c, text)
+	h.snippet = &snippetState{start: start, stops: stops}
+	h.selectSnippetStop()
+	return true
+}
+
+// selectSnippetStop selects the default text of the current tab stop, and
+// leaves the snippet if it is the final one
+func (h *BufPane) selectSnippetStop() {
+	s := h.snippet
+	stop := s.stops[0]
+	from := s.start.Move(stop.Start, h.Buf)
+	to := s.start.Move(stop.End, h.Buf)
+
+	h.Cursor.ResetSelection()
+	if from != to {
+		h.Cursor.SetSelectionStart(from)
+		h.Cursor.SetSelectionEnd(to)
+	}
+	h.Cursor.GotoLoc(to)
+	h.Relocate()
+
+	s.size = bufferSize(h.Buf)
+	if stop.Index == 0 || len(s.stops) == 1 {
+		h.snippet = nil
+	}
+}
+
+// inSnippetStop returns whether the cursor is still in the current tab stop
+// of the snippet. The snippet is left otherwise, since its stops can't be
+// trusted once the cursor was moved away or the snippet was undone
+func (h *BufPane) inSnippetStop() bool {
+	s := h.snippet
+	if h.Buf.NumCursors() > 1 || s.start.GreaterThan(h.Buf.End()) {
+		return false
+	}
+	cur := s.stops[0]
+	end := cur.End + bufferSize(h.Buf) - s.size
+	if end < cur.Start {
+		return false
+	}
+	c := h.Cursor.Loc
+	return c.GreaterEqual(s.start.Move(cur.Start, h.Buf)) && c.LessEqual(s.start.Move(end, h.Buf))
+}
+
+// nextSnippetStop moves to the next tab stop, shifting the ones after the
+// current one by the size of the text typed in it
+func (h *BufPane) nextSnippetStop() bool {
+	s := h.snippet
+	delta := bufferSize(h.Buf) - s.size
+	cur := s.stops[0]
+	s.stops = s.stops[1:]
+	for i := range s.stops {
+		if s.stops[i].Start >= cur.End {
+			s.stops[i].Start += delta
+			s.stops[i].End += delta
+		}
+	}
+	h.selectSnippetStop()
+	return true
+}
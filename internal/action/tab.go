@@ -1,6 +1,8 @@
 package action
 
 import (
+	"log"
+
 	luar "layeh.com/gopher-luar"
 
 	"github.com/zyedidia/micro/v2/internal/buffer"
@@ -75,6 +77,14 @@ func (t *TabList) RemoveTab(id uint64) {
 	}
 }
 
+// SetActive changes the currently active tab to the specified index
+func (t *TabList) SetActive(a int) {
+	if cur := t.Active(); cur != a && cur < len(t.List) {
+		recordLocation(t.List[cur].CurPane())
+	}
+	t.TabWindow.SetActive(a)
+}
+
 // Resize resizes all elements within the tab list
 // One thing to note is that when there is only 1 tab
 // the tab bar should not be drawn so resizing must take
@@ -297,6 +307,9 @@ func (t *Tab) HandleEvent(event tcell.Event) {
 
 // SetActive changes the currently active pane to the specified index
 func (t *Tab) SetActive(i int) {
+	if i != t.active && t.active < len(t.Panes) {
+		recordLocation(t.Panes[t.active])
+	}
 	t.active = i
 	for j, p := range t.Panes {
 		if j == i {
@@ -352,3 +365,57 @@ func (t *Tab) CurPane() *BufPane {
 	}
 	return p
 }
+
+// saveState saves the state which is only saved when macro exits
+func saveState() {
+	SaveSession()
+	if err := config.SaveViewPrefs(); err != nil {
+		log.Println("Error saving view preferences:", err)
+	}
+}
+
+// SaveSession saves the files open in all the tabs and splits, so that they
+// can be opened again the next time macro is started without any file
+func SaveSession() {
+	if !config.GetGlobalOption("savesession").(bool) {
+		return
+	}
+
+	var s config.Session
+	seen := make(map[string]bool)
+	cur := MainTab().CurPane()
+	for _, t := range Tabs.List {
+		for _, p := range t.Panes {
+			bp, ok := p.(*BufPane)
+			if !ok || bp.Buf.Path == "" || bp.Buf.Type != buffer.BTDefault || seen[bp.Buf.AbsPath] {
+				continue
+			}
+			seen[bp.Buf.AbsPath] = true
+			if bp == cur {
+				s.Current = len(s.Buffers)
+			}
+			s.Buffers = append(s.Buffers, config.SessionBuffer{
+				Path: bp.Buf.AbsPath,
+				X:    bp.Cursor.X,
+				Y:    bp.Cursor.Y,
+			})
+		}
+	}
+
+	if err := config.SaveSession(s); err != nil {
+		log.Println("Error saving session:", err)
+	}
+}
+
+// ActivateBuffer makes the pane displaying the given buffer the current one
+func ActivateBuffer(b *buffer.Buffer) {
+	for i, t := range Tabs.List {
+		for j, p := range t.Panes {
+			if bp, ok := p.(*BufPane); ok && bp.Buf == b {
+				Tabs.SetActive(i)
+				t.SetActive(j)
+				return
+			}
+		}
+	}
+}
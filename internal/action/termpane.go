@@ -99,6 +99,7 @@ func (t *TermPane) Quit() {
 	} else if len(Tabs.List) > 1 {
 		Tabs.RemoveTab(t.id)
 	} else {
+		saveState()
 		screen.Screen.Fini()
 		InfoBar.Close()
 		runtime.Goexit()
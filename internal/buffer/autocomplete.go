@@ -25,6 +25,7 @@ func (b *Buffer) GetSuggestions() {
 
 // Autocomplete starts the autocomplete process
 func (b *Buffer) Autocomplete(c Completer) bool {
+	b.replacesArg = false
 	b.Completions, b.Suggestions = c(b)
 	if len(b.Completions) != len(b.Suggestions) || len(b.Completions) == 0 {
 		return false
@@ -34,18 +35,33 @@ func (b *Buffer) Autocomplete(c Completer) bool {
 	return true
 }
 
+// ReplaceArg returns the given suggestions as completions that replace the
+// whole argument before the cursor, for the completers whose suggestions
+// don't start with the argument (e.g. fuzzy matches). The argument is
+// restored when cycling past the last or first suggestion
+func (b *Buffer) ReplaceArg(suggestions []string) ([]string, []string) {
+	b.replacesArg = true
+	b.arg, _ = b.GetArg()
+	return suggestions, suggestions
+}
+
 // CycleAutocomplete moves to the next suggestion
 func (b *Buffer) CycleAutocomplete(forward bool) {
 	prevSuggestion := b.CurSuggestion
 
+	// when the completions replace the argument, it is the suggestion -1
+	first := 0
+	if b.replacesArg {
+		first = -1
+	}
 	if forward {
 		b.CurSuggestion++
 	} else {
 		b.CurSuggestion--
 	}
 	if b.CurSuggestion >= len(b.Suggestions) {
-		b.CurSuggestion = 0
-	} else if b.CurSuggestion < 0 {
+		b.CurSuggestion = first
+	} else if b.CurSuggestion < first {
 		b.CurSuggestion = len(b.Suggestions) - 1
 	}
 
@@ -54,9 +70,15 @@ func (b *Buffer) CycleAutocomplete(forward bool) {
 	end := c.Loc
 	if prevSuggestion < len(b.Suggestions) && prevSuggestion >= 0 {
 		start = end.Move(-util.CharacterCountInString(b.Completions[prevSuggestion]), b)
+	} else if b.replacesArg {
+		start = end.Move(-util.CharacterCountInString(b.arg), b)
 	}
 
-	b.Replace(start, end, b.Completions[b.CurSuggestion])
+	completion := b.arg
+	if b.CurSuggestion >= 0 {
+		completion = b.Completions[b.CurSuggestion]
+	}
+	b.Replace(start, end, completion)
 	if len(b.Suggestions) > 1 {
 		b.HasSuggestions = true
 	}
@@ -123,6 +123,7 @@ func (b *Buffer) ApplyBackup(fsize int64) (bool, bool) {
 					// recover
 					b.LineArray = NewLineArray(uint64(fsize), FFAuto, backup)
 					b.isModified = true
+					b.sizeValid = false
 					return true, true
 				} else if choice%3 == 1 {
 					// delete
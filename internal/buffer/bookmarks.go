@@ -0,0 +1,111 @@
+package buffer
+
+import (
+	"sort"
+
+	"github.com/zyedidia/micro/v2/internal/config"
+)
+
+// Bookmarked returns whether the given line is bookmarked
+func (b *SharedBuffer) Bookmarked(y int) bool {
+	for _, m := range b.bookmarks {
+		if m == y {
+			return true
+		}
+	}
+	return false
+}
+
+// Bookmarks returns the bookmarked lines, in order
+func (b *SharedBuffer) Bookmarks() []int {
+	return append([]int(nil), b.bookmarks...)
+}
+
+// ToggleBookmark bookmarks the given line, or removes its bookmark if it
+// already has one. It returns whether the line is now bookmarked
+func (b *Buffer) ToggleBookmark(y int) bool {
+	added := !b.Bookmarked(y)
+	if added {
+		b.bookmarks = append(b.bookmarks, y)
+		sort.Ints(b.bookmarks)
+	} else {
+		marks := b.bookmarks[:0]
+		for _, m := range b.bookmarks {
+			if m != y {
+				marks = append(marks, m)
+			}
+		}
+		b.bookmarks = marks
+	}
+	b.saveBookmarks()
+	return added
+}
+
+// NextBookmark returns the first bookmarked line after (or before, if
+// forward is false) the given line, wrapping around the buffer. It returns
+// false if there are no bookmarks
+func (b *SharedBuffer) NextBookmark(y int, forward bool) (int, bool) {
+	n := len(b.bookmarks)
+	if n == 0 {
+		return 0, false
+	}
+	if forward {
+		for _, m := range b.bookmarks {
+			if m > y {
+				return m, true
+			}
+		}
+		return b.bookmarks[0], true
+	}
+	for i := n - 1; i >= 0; i-- {
+		if b.bookmarks[i] < y {
+			return b.bookmarks[i], true
+		}
+	}
+	return b.bookmarks[n-1], true
+}
+
+// loadBookmarks reads the saved bookmarks of the file, dropping the ones
+// past the end of the buffer
+func (b *Buffer) loadBookmarks() {
+	if b.Type != BTDefault || b.Path == "" {
+		return
+	}
+	for _, m := range config.LoadBookmarks(b.AbsPath) {
+		if m >= 0 && m < b.LinesNum() && !b.Bookmarked(m) {
+			b.bookmarks = append(b.bookmarks, m)
+		}
+	}
+	sort.Ints(b.bookmarks)
+}
+
+// saveBookmarks saves the bookmarks of the file. Bookmarks are only a
+// convenience, so errors are ignored
+func (b *Buffer) saveBookmarks() {
+	if b.Type != BTDefault || b.Path == "" {
+		return
+	}
+	config.SaveBookmarks(b.AbsPath, b.bookmarks)
+}
+
+// shiftBookmarks moves the bookmarks after an edit, so that they stay on the
+// same lines of text: lines inserted (n > 0) or removed (n < 0) at line y
+// shift the bookmarks below, and bookmarks on removed lines move to line y
+func (b *SharedBuffer) shiftBookmarks(y, n int) {
+	if n == 0 || len(b.bookmarks) == 0 {
+		return
+	}
+	marks := b.bookmarks[:0]
+	for _, m := range b.bookmarks {
+		if m > y {
+			m = m + n
+			if m < y {
+				m = y
+			}
+		}
+		if len(marks) == 0 || marks[len(marks)-1] != m {
+			marks = append(marks, m)
+		}
+	}
+	b.bookmarks = marks
+}
@@ -16,6 +16,7 @@ import (
 	"sync"
 	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	luar "layeh.com/gopher-luar"
 
@@ -94,9 +95,16 @@ type SharedBuffer struct {
 	Suggestions   []string
 	Completions   []string
 	CurSuggestion int
+	// replacesArg is whether the completions replace the argument before
+	// the cursor, which was arg, instead of being inserted after it
+	replacesArg bool
+	arg         string
 
 	Messages []*Message
 
+	// bookmarks are the bookmarked lines, in order
+	bookmarks []int
+
 	updateDiffTimer   *time.Timer
 	diffBase          []byte
 	diffBaseLineCount int
@@ -105,6 +113,25 @@ type SharedBuffer struct {
 
 	requestedBackup bool
 
+	// whether the file had some content but no final newline when it was
+	// loaded, for the preserve value of the finalnewline option
+	noEOFNewline bool
+
+	// brackets caches the open brackets at the start of lines, for the
+	// rainbowbrackets option
+	brackets bracketCache
+
+	// matches caches the matches of the last search counted by MatchIndex
+	matches *matchCache
+
+	// size caches the result of Size while sizeValid, since the statusline
+	// may show it on every redraw
+	size      int
+	sizeValid bool
+
+	// contentTimer debounces the content changed notifications
+	contentTimer *time.Timer
+
 	// ReloadDisabled allows the user to disable reloads if they
 	// are viewing a file that is constantly changing
 	ReloadDisabled bool
@@ -132,12 +159,21 @@ func (b *SharedBuffer) insert(pos Loc, value []byte) {
 	b.LineArray.insert(pos, value)
 
 	inslines := bytes.Count(value, []byte{'\n'})
+	if pos.X == 0 {
+		// the whole line moves down
+		b.shiftBookmarks(pos.Y-1, inslines)
+	} else {
+		b.shiftBookmarks(pos.Y, inslines)
+	}
 	b.MarkModified(pos.Y, pos.Y+inslines)
+	b.contentModified()
 }
 func (b *SharedBuffer) remove(start, end Loc) []byte {
 	b.isModified = true
 	b.HasSuggestions = false
 	defer b.MarkModified(start.Y, end.Y)
+	defer b.contentModified()
+	b.shiftBookmarks(start.Y, start.Y-end.Y)
 	return b.LineArray.remove(start, end)
 }
 
@@ -145,6 +181,9 @@ func (b *SharedBuffer) remove(start, end Loc) []byte {
 // and performs rehighlighting if syntax highlighting is enabled
 func (b *SharedBuffer) MarkModified(start, end int) {
 	b.ModifiedThisFrame = true
+	b.resetBrackets(start)
+	b.matches = nil
+	b.sizeValid = false
 
 	start = util.Clamp(start, 0, len(b.lines)-1)
 	end = util.Clamp(end, 0, len(b.lines)-1)
@@ -211,6 +250,11 @@ type Buffer struct {
 	LastSearchRegex bool
 	// HighlightSearch enables highlighting all instances of the last successful search
 	HighlightSearch bool
+
+	// SaveBackupErr is the error of the copy of the previous content of the
+	// file made by the last save (see the savebackup option). It doesn't
+	// prevent the save
+	SaveBackupErr error
 }
 
 // NewBufferFromFileAtLoc opens a new buffer with a given cursor location
@@ -262,6 +306,13 @@ func NewBufferFromFileAtLoc(path string, btype BufType, cursorLoc Loc) (*Buffer,
 		if buf == nil {
 			return nil, errors.New("could not open file")
 		}
+		if btype == BTDefault {
+			// the recent files list is only a convenience, so errors are ignored
+			config.AddRecentFile(buf.AbsPath)
+		}
+		if buf.MixedEndings && prompt != nil {
+			prompt.Message("Warning: file has mixed line endings - they will be saved as LF")
+		}
 	}
 
 	if readonly && prompt != nil {
@@ -290,6 +341,35 @@ func NewBufferFromString(text, path string, btype BufType) *Buffer {
 	return NewBuffer(strings.NewReader(text), int64(len(text)), path, Loc{-1, -1}, btype)
 }
 
+// sniffSize is the number of bytes looked at to guess the encoding of a file
+const sniffSize = 64 * 1024
+
+// sniffEncoding guesses the encoding of a file from its first bytes: UTF-16
+// if it starts with a UTF-16 byte order mark, UTF-8 if it is valid UTF-8 and
+// ISO-8859-1 otherwise
+func sniffEncoding(br *bufio.Reader) string {
+	data, _ := br.Peek(sniffSize)
+	if bytes.HasPrefix(data, []byte{0xff, 0xfe}) {
+		return "utf-16le"
+	} else if bytes.HasPrefix(data, []byte{0xfe, 0xff}) {
+		return "utf-16be"
+	}
+
+	if len(data) == sniffSize {
+		// the data may end in the middle of a character
+		for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+			if utf8.RuneStart(data[i]) {
+				data = data[:i]
+				break
+			}
+		}
+	}
+	if !utf8.Valid(data) {
+		return "iso-8859-1"
+	}
+	return "utf-8"
+}
+
 // NewBuffer creates a new buffer from a given reader with a given path
 // Ensure that ReadSettings and InitGlobalSettings have been called before creating
 // a new buffer
@@ -341,6 +421,25 @@ func NewBuffer(r io.Reader, size int64, path string, startcursor Loc, btype BufT
 		b.Settings["readonly"] = settings["readonly"]
 		b.Settings["filetype"] = settings["filetype"]
 		b.Settings["syntax"] = settings["syntax"]
+		if limit := util.IntOpt(settings["largefilesize"]); limit > 0 && size > int64(limit) {
+			// syntax highlighting is disabled for large files, but it can
+			// still be enabled for this buffer with `setlocal syntax on`
+			b.Settings["syntax"] = false
+		}
+
+		// only the files opened by the user may be in another encoding,
+		// the other buffers are created by micro
+		if btype == BTDefault && size > 0 && settings["encoding"] == "utf-8" {
+			br := bufio.NewReaderSize(r, sniffSize)
+			r = br
+			if e := sniffEncoding(br); e != "utf-8" {
+				settings["encoding"] = e
+				b.Settings["encoding"] = e
+				if prompt != nil {
+					prompt.Message(fmt.Sprintf("Warning: file is not UTF-8 - it was opened as %s, editing it may be lossy", e))
+				}
+			}
+		}
 
 		enc, err := htmlindex.Get(settings["encoding"].(string))
 		if err != nil {
@@ -375,7 +474,11 @@ func NewBuffer(r io.Reader, size int64, path string, startcursor Loc, btype BufT
 		b.EventHandler = NewEventHandler(b.SharedBuffer, b.cursors)
 
 		// The last time this file was modified
-		b.UpdateModTime()
+		if b.UpdateModTime() == nil {
+			// new buffers are not trimmed, even if they don't end with a
+			// newline yet
+			b.noEOFNewline = b.missesFinalNewline()
+		}
 	}
 
 	if b.Settings["readonly"].(bool) && b.Type == BTDefault {
@@ -393,8 +496,8 @@ func NewBuffer(r io.Reader, size int64, path string, startcursor Loc, btype BufT
 	// init local settings again now that we know the filetype
 	config.InitLocalSettings(b.Settings, b.Path)
 
-	if _, err := os.Stat(filepath.Join(config.ConfigDir, "buffers")); os.IsNotExist(err) {
-		os.Mkdir(filepath.Join(config.ConfigDir, "buffers"), os.ModePerm)
+	if _, err := os.Stat(config.StatePath("buffers")); os.IsNotExist(err) {
+		os.Mkdir(config.StatePath("buffers"), os.ModePerm)
 	}
 
 	if startcursor.X != -1 && startcursor.Y != -1 {
@@ -406,6 +509,8 @@ func NewBuffer(r io.Reader, size int64, path string, startcursor Loc, btype BufT
 		}
 	}
 
+	b.loadBookmarks()
+
 	b.AddCursor(NewCursor(b, b.StartCursor))
 	b.GetActiveCursor().Relocate()
 
@@ -551,6 +656,7 @@ func (b *Buffer) ReOpen() error {
 		return err
 	}
 	b.EventHandler.ApplyDiff(txt)
+	b.noEOFNewline = b.missesFinalNewline()
 
 	err = b.UpdateModTime()
 	if !b.Settings["fastdirty"].(bool) {
@@ -634,6 +740,9 @@ func (b *Buffer) Modified() bool {
 
 // Size returns the number of bytes in the current buffer
 func (b *Buffer) Size() int {
+	if b.sizeValid {
+		return b.size
+	}
 	nb := 0
 	for i := 0; i < b.LinesNum(); i++ {
 		nb += len(b.LineBytes(i))
@@ -645,6 +754,7 @@ func (b *Buffer) Size() int {
 			nb++ // newline
 		}
 	}
+	b.size, b.sizeValid = nb, true
 	return nb
 }
 
@@ -964,6 +1074,7 @@ func (b *Buffer) UpdateRules() {
 			go func() {
 				b.Highlighter.HighlightStates(b)
 				b.Highlighter.HighlightMatches(b, 0, b.End().Y)
+				b.resetBrackets(0)
 				screen.Redraw()
 			}()
 		}
@@ -976,6 +1087,7 @@ func (b *Buffer) ClearMatches() {
 		b.SetMatch(i, nil)
 		b.SetState(i, nil)
 	}
+	b.resetBrackets(0)
 }
 
 // IndentString returns this buffer's indent method (a tabstop or n spaces
@@ -1092,18 +1204,29 @@ func (b *Buffer) MoveLinesUp(start int, end int) {
 	}
 	l := string(b.LineBytes(start - 1))
 	if end == len(b.lines) {
-		b.insert(
-			Loc{
-				util.CharacterCount(b.lines[end-1].data),
-				end - 1,
-			},
-			[]byte{'\n'},
+		// The last line has no newline to insert the line above before, so
+		// the line above is appended after it instead. This would push the
+		// cursors at the end of the buffer after the appended line, so they
+		// are put back
+		last := Loc{util.CharacterCount(b.LineBytes(end - 1)), end - 1}
+		var atEnd []*Loc
+		for _, c := range b.cursors {
+			for _, loc := range []*Loc{&c.Loc, &c.CurSelection[0], &c.CurSelection[1]} {
+				if *loc == last {
+					atEnd = append(atEnd, loc)
+				}
+			}
+		}
+		b.Insert(last, "\n"+l)
+		for _, loc := range atEnd {
+			*loc = last
+		}
+	} else {
+		b.Insert(
+			Loc{0, end},
+			l+"\n",
 		)
 	}
-	b.Insert(
-		Loc{0, end},
-		l+"\n",
-	)
 	b.Remove(
 		Loc{0, start - 1},
 		Loc{0, start},
@@ -1121,10 +1244,18 @@ func (b *Buffer) MoveLinesDown(start int, end int) {
 		l+"\n",
 	)
 	end++
-	b.Remove(
-		Loc{0, end},
-		Loc{0, end + 1},
-	)
+	if end == len(b.lines)-1 {
+		// the last line has no newline to remove, remove the previous one
+		b.Remove(
+			Loc{util.CharacterCount(b.LineBytes(end - 1)), end - 1},
+			Loc{util.CharacterCount(b.LineBytes(end)), end},
+		)
+	} else {
+		b.Remove(
+			Loc{0, end},
+			Loc{0, end + 1},
+		)
+	}
 }
 
 var BracePairs = [][2]rune{
@@ -1238,6 +1369,191 @@ func (b *Buffer) Retab() {
 	b.isModified = dirty
 }
 
+// Reindent normalizes the leading whitespace of every line to the
+// indentation style of the buffer's settings and returns the number
+// of lines that were changed
+func (b *Buffer) Reindent() int {
+	toSpaces := b.Settings["tabstospaces"].(bool)
+	tabsize := util.IntOpt(b.Settings["tabsize"])
+
+	var deltas []Delta
+	for i := 0; i < b.LinesNum(); i++ {
+		l := b.LineBytes(i)
+		ws := util.GetLeadingWhitespace(l)
+		if len(ws) == 0 || len(ws) == len(l) {
+			continue
+		}
+
+		indent := util.Reindent(ws, tabsize, toSpaces)
+		if !bytes.Equal(ws, indent) {
+			deltas = append(deltas, Delta{indent, Loc{0, i}, Loc{util.CharacterCount(ws), i}})
+		}
+	}
+
+	if len(deltas) > 0 {
+		b.MultipleReplace(deltas)
+	}
+	return len(deltas)
+}
+
+// indentWidth returns the visual width of the leading whitespace of
+// the given line, and whether the line is blank
+func (b *Buffer) indentWidth(y int) (int, bool) {
+	l := b.LineBytes(y)
+	ws := util.GetLeadingWhitespace(l)
+	if len(ws) == len(l) {
+		return 0, true
+	}
+	return util.StringWidth(ws, util.CharacterCount(ws), util.IntOpt(b.Settings["tabsize"])), false
+}
+
+// FindBlockBoundary returns the nearest line after (or before) line y whose
+// indentation is less than the indentation of line y, that is the line ending
+// (or starting) the indentation block containing line y. Blank lines are
+// skipped, and a blank line y belongs to the block of the nearest non-blank
+// line above it. If there is no such line, the last (or first) non-blank
+// line of the buffer is returned.
+func (b *Buffer) FindBlockBoundary(y int, forward bool) int {
+	level, blank := b.indentWidth(y)
+	for l := y - 1; blank && l >= 0; l-- {
+		level, blank = b.indentWidth(l)
+	}
+
+	dir := -1
+	if forward {
+		dir = 1
+	}
+	edge := y
+	for l := y + dir; l >= 0 && l < b.LinesNum(); l += dir {
+		w, blank := b.indentWidth(l)
+		if blank {
+			continue
+		}
+		if w < level {
+			return l
+		}
+		edge = l
+	}
+	return edge
+}
+
+// ContextLine returns the nearest line above line y with less indentation,
+// that is the line starting the indentation block containing line y, or -1
+// if there is none. A blank line y belongs to the block of the nearest
+// non-blank line below it
+func (b *Buffer) ContextLine(y int) int {
+	level, blank := b.indentWidth(y)
+	for l := y + 1; blank && l < b.LinesNum(); l++ {
+		level, blank = b.indentWidth(l)
+	}
+	if blank || level == 0 {
+		return -1
+	}
+
+	for l := y - 1; l >= 0; l-- {
+		if w, blank := b.indentWidth(l); !blank && w < level {
+			return l
+		}
+	}
+	return -1
+}
+
+// OpenLine inserts an empty line below (or above) line y and returns the
+// location at which editing should continue on it. When autoindent is on,
+// the new line gets the indentation of line y
+func (b *Buffer) OpenLine(y int, above bool) Loc {
+	var ws []byte
+	if b.Settings["autoindent"].(bool) {
+		ws = util.GetLeadingWhitespace(b.LineBytes(y))
+	}
+
+	if above {
+		b.Insert(Loc{0, y}, string(ws)+"\n")
+	} else {
+		b.Insert(Loc{util.CharacterCount(b.LineBytes(y)), y}, "\n"+string(ws))
+		y++
+	}
+	return Loc{util.CharacterCount(ws), y}
+}
+
+// TransformLines replaces the lines from start to end (inclusive) with the
+// result of the given function. The empty line following the final newline
+// of the buffer is left untouched, so that the buffer keeps ending with a
+// newline
+func (b *Buffer) TransformLines(start, end int, fn func(lines []string) []string) {
+	if end == b.LinesNum()-1 && end > start && len(b.LineBytes(end)) == 0 {
+		end--
+	}
+
+	lines := make([]string, 0, end-start+1)
+	for i := start; i <= end; i++ {
+		lines = append(lines, b.Line(i))
+	}
+	text := strings.Join(fn(lines), "\n")
+
+	endLoc := Loc{util.CharacterCount(b.LineBytes(end)), end}
+	if text != strings.Join(lines, "\n") {
+		b.Replace(Loc{0, start}, endLoc, text)
+	}
+}
+
+// OpensIndent returns whether the given text, the beginning of a line, ends
+// with an opening bracket or, for filetypes where blocks start with a colon,
+// with a colon, so that the next line should be indented one more level
+func (b *Buffer) OpensIndent(text []byte) bool {
+	text = bytes.TrimRightFunc(text, util.IsWhitespace)
+	if len(text) == 0 {
+		return false
+	}
+	switch text[len(text)-1] {
+	case '{', '(', '[':
+		return true
+	case ':':
+		switch b.Settings["filetype"] {
+		case "python", "yaml", "nim", "crystal":
+			return true
+		}
+	}
+	return false
+}
+
+// ClosesIndent returns whether the given text, the end of a line, starts with
+// the bracket closing the one the given beginning of the line ends with, so
+// that the closing bracket should go back to the indentation of the line
+func ClosesIndent(before, after []byte) bool {
+	before = bytes.TrimRightFunc(before, util.IsWhitespace)
+	after = bytes.TrimLeftFunc(after, util.IsWhitespace)
+	if len(before) == 0 || len(after) == 0 {
+		return false
+	}
+	for _, bp := range BracePairs {
+		if rune(before[len(before)-1]) == bp[0] && rune(after[0]) == bp[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// TrimTrailingWhitespace removes the whitespace at the end of every line
+// except the skip line (-1 to trim all lines). Whitespace-only lines are
+// emptied but kept. It returns the number of trimmed lines
+func (b *Buffer) TrimTrailingWhitespace(skip int) int {
+	n := 0
+	for i := 0; i < b.LinesNum(); i++ {
+		if i == skip {
+			continue
+		}
+		l := b.LineBytes(i)
+		linelen := util.CharacterCount(l)
+		if ws := util.CharacterCount(util.GetTrailingWhitespace(l)); ws > 0 {
+			b.Remove(Loc{linelen - ws, i}, Loc{linelen, i})
+			n++
+		}
+	}
+	b.RelocateCursors()
+	return n
+}
+
 // ParseCursorLocation turns a cursor location like 10:5 (LINE:COL)
 // into a loc
 func ParseCursorLocation(cursorPositions []string) (Loc, error) {
@@ -1273,6 +1589,30 @@ func (b *Buffer) Write(bytes []byte) (n int, err error) {
 	return len(bytes), nil
 }
 
+// linesToRunes encodes each distinct line of the given texts as a single rune
+// so that they can be diffed line by line
+func linesToRunes(text1, text2 string) ([]rune, []rune) {
+	lines := make(map[string]rune)
+	encode := func(text string) []rune {
+		var runes []rune
+		for len(text) > 0 {
+			i := strings.IndexByte(text, '\n') + 1
+			if i == 0 {
+				i = len(text)
+			}
+			r, ok := lines[text[:i]]
+			if !ok {
+				r = rune(len(lines))
+				lines[text[:i]] = r
+			}
+			runes = append(runes, r)
+			text = text[i:]
+		}
+		return runes
+	}
+	return encode(text1), encode(text2)
+}
+
 func (b *Buffer) updateDiffSync() {
 	b.diffLock.Lock()
 	defer b.diffLock.Unlock()
@@ -1284,7 +1624,7 @@ func (b *Buffer) updateDiffSync() {
 	}
 
 	differ := dmp.New()
-	baseRunes, bufferRunes, _ := differ.DiffLinesToRunes(string(b.diffBase), string(b.Bytes()))
+	baseRunes, bufferRunes := linesToRunes(string(b.diffBase), string(b.Bytes()))
 	diffs := differ.DiffMainRunes(baseRunes, bufferRunes, false)
 	lineN := 0
 
@@ -1360,6 +1700,16 @@ func (b *Buffer) SetDiffBase(diffBase []byte) {
 	})
 }
 
+// DiffBuffers sets each buffer's diff base to the contents of the other one
+// and enables the diff gutter, so that the differences between both buffers
+// are shown side by side
+func DiffBuffers(a, b *Buffer) {
+	a.SetOptionNative("diffgutter", true)
+	b.SetOptionNative("diffgutter", true)
+	a.SetDiffBase(b.Bytes())
+	b.SetDiffBase(a.Bytes())
+}
+
 // DiffStatus returns the diff status for a line in the buffer
 func (b *Buffer) DiffStatus(lineN int) DiffStatus {
 	b.diffLock.RLock()
@@ -1403,6 +1753,24 @@ func (b *Buffer) FindNextDiffLine(startLine int, forward bool) (int, error) {
 	}
 }
 
+// NextDiffLine is like FindNextDiffLine but wraps around the buffer. It
+// returns false if the buffer has no diffs
+func (b *Buffer) NextDiffLine(startLine int, forward bool) (int, bool) {
+	if l, err := b.FindNextDiffLine(startLine, forward); err == nil {
+		return l, true
+	}
+
+	from := 0
+	if !forward {
+		from = b.LinesNum() - 1
+	}
+	if b.DiffStatus(from) != DSUnchanged {
+		return from, true
+	}
+	l, err := b.FindNextDiffLine(from, forward)
+	return l, err == nil
+}
+
 // SearchMatch returns true if the given location is within a match of the last search.
 // It is used for search highlighting
 func (b *Buffer) SearchMatch(pos Loc) bool {
@@ -2,8 +2,11 @@ package buffer
 
 import (
 	"math/rand"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	lua "github.com/yuin/gopher-lua"
@@ -321,3 +324,639 @@ func BenchmarkEdit1000000Lines100Cursors(b *testing.B) {
 func BenchmarkEdit1000000Lines1000Cursors(b *testing.B) {
 	benchEdit(b, 1000000, 1000)
 }
+
+func TestTransformLines(t *testing.T) {
+	b := NewBufferFromString("one\ntwo\nthree\n", "", BTDefault)
+	b.TransformLines(0, b.LinesNum()-1, util.ReverseLines)
+	assert.Equal(t, "three\ntwo\none\n", string(b.Bytes()))
+
+	b = NewBufferFromString("one\ntwo\nthree", "", BTDefault)
+	b.TransformLines(0, b.LinesNum()-1, util.ReverseLines)
+	assert.Equal(t, "three\ntwo\none", string(b.Bytes()))
+
+	b = NewBufferFromString("one\ntwo\nthree\nfour\n", "", BTDefault)
+	b.TransformLines(1, 2, util.ReverseLines)
+	assert.Equal(t, "one\nthree\ntwo\nfour\n", string(b.Bytes()))
+
+	b.UndoOneEvent()
+	b.UndoOneEvent()
+	assert.Equal(t, "one\ntwo\nthree\nfour\n", string(b.Bytes()))
+}
+
+func TestOpenLine(t *testing.T) {
+	b := NewBufferFromString("func f() {\n\tfoo()\n}\n", "", BTDefault)
+	assert.Equal(t, Loc{1, 2}, b.OpenLine(1, false))
+	assert.Equal(t, "func f() {\n\tfoo()\n\t\n}\n", string(b.Bytes()))
+
+	assert.Equal(t, Loc{1, 1}, b.OpenLine(1, true))
+	assert.Equal(t, "func f() {\n\t\n\tfoo()\n\t\n}\n", string(b.Bytes()))
+
+	assert.Equal(t, Loc{0, 0}, b.OpenLine(0, true))
+	assert.Equal(t, Loc{0, 7}, b.OpenLine(6, false))
+	assert.Equal(t, 8, b.LinesNum())
+
+	b = NewBufferFromString("    foo", "", BTDefault)
+	b.Settings["autoindent"] = false
+	assert.Equal(t, Loc{0, 1}, b.OpenLine(0, false))
+	assert.Equal(t, "    foo\n", string(b.Bytes()))
+}
+
+func TestMoveLines(t *testing.T) {
+	src := "type T struct {\n\tA int\n\tB string\n}\n"
+	b := NewBufferFromString(src, "", BTDefault)
+	c := b.GetActiveCursor()
+	c.GotoLoc(Loc{3, 2})
+
+	b.MoveLinesUp(2, 3)
+	assert.Equal(t, "type T struct {\n\tB string\n\tA int\n}\n", string(b.Bytes()))
+	assert.Equal(t, Loc{3, 1}, c.Loc)
+
+	b.MoveLinesDown(1, 2)
+	assert.Equal(t, src, string(b.Bytes()))
+	assert.Equal(t, Loc{3, 2}, c.Loc)
+
+	b.UndoOneEvent()
+	b.UndoOneEvent()
+	b.UndoOneEvent()
+	b.UndoOneEvent()
+	assert.Equal(t, src, string(b.Bytes()))
+
+	// the last line, without a final newline
+	b = NewBufferFromString("one\ntwo", "", BTDefault)
+	c = b.GetActiveCursor()
+	c.GotoLoc(Loc{3, 1})
+	b.MoveLinesUp(1, 2)
+	assert.Equal(t, "two\none", string(b.Bytes()))
+	assert.Equal(t, Loc{3, 0}, c.Loc)
+	b.UndoOneEvent()
+	b.UndoOneEvent()
+	assert.Equal(t, "one\ntwo", string(b.Bytes()))
+
+	c.GotoLoc(Loc{1, 0})
+	b.MoveLinesDown(0, 1)
+	assert.Equal(t, "two\none", string(b.Bytes()))
+	assert.Equal(t, Loc{1, 1}, c.Loc)
+	b.UndoOneEvent()
+	b.UndoOneEvent()
+	assert.Equal(t, "one\ntwo", string(b.Bytes()))
+
+	// out of range moves are no-ops
+	b.MoveLinesUp(0, 1)
+	b.MoveLinesDown(1, 2)
+	assert.Equal(t, "one\ntwo", string(b.Bytes()))
+}
+
+func TestFindBlockBoundary(t *testing.T) {
+	b := NewBufferFromString(strings.Join([]string{
+		"def foo():",  // 0
+		"    if x:",   // 1
+		"        y()", // 2
+		"",            // 3
+		"        z()", // 4
+		"    return",  // 5
+		"",            // 6
+		"def bar():",  // 7
+		"\tpass",      // 8
+	}, "\n"), "", BTDefault)
+	b.Settings["tabsize"] = float64(4)
+
+	assert.Equal(t, 5, b.FindBlockBoundary(2, true))
+	assert.Equal(t, 1, b.FindBlockBoundary(4, false))
+	assert.Equal(t, 0, b.FindBlockBoundary(5, false))
+	assert.Equal(t, 7, b.FindBlockBoundary(5, true))
+
+	// blank lines belong to the block above them
+	assert.Equal(t, 5, b.FindBlockBoundary(3, true))
+	assert.Equal(t, 7, b.FindBlockBoundary(6, true))
+
+	// tabs count as their visual width
+	assert.Equal(t, 7, b.FindBlockBoundary(8, false))
+
+	// at the edges, the first or last non-blank line is used
+	assert.Equal(t, 8, b.FindBlockBoundary(7, true))
+	assert.Equal(t, 0, b.FindBlockBoundary(7, false))
+	assert.Equal(t, 8, b.FindBlockBoundary(8, true))
+}
+
+func TestContextLine(t *testing.T) {
+	b := NewBufferFromString(strings.Join([]string{
+		"func foo() {", // 0
+		"    if x {",   // 1
+		"        y()",  // 2
+		"",             // 3
+		"        z()",  // 4
+		"    }",        // 5
+		"}",            // 6
+		"",             // 7
+		"\tbar()",      // 8
+	}, "\n"), "", BTDefault)
+	b.Settings["tabsize"] = float64(4)
+
+	assert.Equal(t, 1, b.ContextLine(2))
+	assert.Equal(t, 0, b.ContextLine(1))
+	assert.Equal(t, 0, b.ContextLine(5))
+	assert.Equal(t, -1, b.ContextLine(0))
+	assert.Equal(t, -1, b.ContextLine(6))
+
+	// blank lines belong to the block below them
+	assert.Equal(t, 1, b.ContextLine(3))
+	assert.Equal(t, 6, b.ContextLine(7))
+	assert.Equal(t, 6, b.ContextLine(8))
+}
+
+func TestAutoSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file.txt")
+	assert.Nil(t, os.WriteFile(path, []byte("foo\n"), 0644))
+	b := NewBuffer(strings.NewReader("foo\n"), 4, path, Loc{-1, -1}, BTDefault)
+	defer b.Close()
+
+	// unmodified buffers are not written
+	assert.Nil(t, os.Remove(path))
+	assert.Nil(t, b.AutoSave())
+	_, err := os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+
+	b.Insert(b.Start(), "x")
+	assert.Nil(t, b.AutoSave())
+	data, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "xfoo\n", string(data))
+	assert.False(t, b.Modified())
+
+	// unnamed buffers are skipped
+	u := NewBufferFromString("foo", "", BTDefault)
+	u.Insert(u.Start(), "x")
+	assert.Nil(t, u.AutoSave())
+}
+
+func TestSaveBackup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file.txt")
+	b := NewBuffer(strings.NewReader(""), 0, path, Loc{-1, -1}, BTDefault)
+	defer b.Close()
+	b.SetOptionNative("savebackup", true)
+
+	// new files have no backup
+	b.Insert(b.Start(), "foo")
+	assert.Nil(t, b.Save())
+	_, err := os.Stat(path + "~")
+	assert.True(t, os.IsNotExist(err))
+
+	b.Insert(b.Start(), "x")
+	assert.Nil(t, b.Save())
+	assert.Nil(t, b.SaveBackupErr)
+	data, err := os.ReadFile(path + "~")
+	assert.Nil(t, err)
+	assert.Equal(t, "foo\n", string(data))
+
+	b.Insert(b.Start(), "y")
+	assert.Nil(t, b.Save())
+	data, err = os.ReadFile(path + "~")
+	assert.Nil(t, err)
+	assert.Equal(t, "xfoo\n", string(data))
+}
+
+func TestFinalNewline(t *testing.T) {
+	dir := t.TempDir()
+	save := func(mode, content string) string {
+		path := filepath.Join(dir, "file.txt")
+		assert.Nil(t, os.WriteFile(path, []byte(content), 0644))
+		b := NewBuffer(strings.NewReader(content), int64(len(content)), path, Loc{-1, -1}, BTDefault)
+		defer b.Close()
+		b.SetOptionNative("finalnewline", mode)
+		// editing doesn't change the original state of the file
+		b.Insert(b.Start(), "x")
+		assert.Nil(t, b.Save())
+		data, err := os.ReadFile(path)
+		assert.Nil(t, err)
+		return string(data)
+	}
+
+	assert.Equal(t, "xfoo\n", save("ensure", "foo\n"))
+	assert.Equal(t, "xfoo\n", save("ensure", "foo"))
+	assert.Equal(t, "xfoo\n\n", save("ensure", "foo\n\n"))
+
+	assert.Equal(t, "xfoo\n", save("preserve", "foo\n"))
+	assert.Equal(t, "xfoo", save("preserve", "foo"))
+
+	assert.Equal(t, "xfoo", save("trim", "foo\n"))
+	assert.Equal(t, "xfoo", save("trim", "foo"))
+	assert.Equal(t, "xfoo", save("trim", "foo\n\n\n"))
+
+	// empty files are not trimmed
+	assert.Equal(t, "x\n", save("preserve", ""))
+
+	// eofnewline off leaves the content untouched
+	config.GlobalSettings["eofnewline"] = false
+	defer func() { config.GlobalSettings["eofnewline"] = true }()
+	assert.Equal(t, "xfoo", save("ensure", "foo"))
+	assert.Equal(t, "xfoo\n", save("ensure", "foo\n"))
+	assert.Equal(t, "xfoo\n\n", save("trim", "foo\n\n"))
+}
+
+func TestFinalNewlineNewBuffer(t *testing.T) {
+	save := func(content string) string {
+		path := filepath.Join(t.TempDir(), "file.txt")
+		b := NewBufferFromString(content, path, BTDefault)
+		defer b.Close()
+		assert.Nil(t, b.Save())
+		data, err := os.ReadFile(path)
+		assert.Nil(t, err)
+		return string(data)
+	}
+
+	config.GlobalSettings["finalnewline"] = "preserve"
+	defer func() { config.GlobalSettings["finalnewline"] = "ensure" }()
+	assert.Equal(t, "a\n\n", save("a\n\n"))
+	assert.Equal(t, "a\n", save("a"))
+
+	config.GlobalSettings["eofnewline"] = false
+	defer func() { config.GlobalSettings["eofnewline"] = true }()
+	assert.Equal(t, "a\n\n", save("a\n\n"))
+	assert.Equal(t, "a", save("a"))
+	assert.Equal(t, "", save(""))
+}
+
+func TestLargeFileSyntax(t *testing.T) {
+	config.GlobalSettings["largefilesize"] = float64(1000)
+	defer func() {
+		config.GlobalSettings["largefilesize"] = config.DefaultCommonSettings()["largefilesize"]
+	}()
+
+	large := strings.Repeat("func foo() {}\n", 100)
+	b := NewBuffer(strings.NewReader(large), int64(len(large)), "large.go", Loc{-1, -1}, BTDefault)
+	assert.False(t, b.Settings["syntax"].(bool))
+
+	small := "func foo() {}\n"
+	s := NewBuffer(strings.NewReader(small), int64(len(small)), "small.go", Loc{-1, -1}, BTDefault)
+	assert.True(t, s.Settings["syntax"].(bool))
+	assert.False(t, b.Settings["syntax"].(bool))
+
+	// highlighting can still be enabled for the large buffer only
+	b.SetOptionNative("syntax", true)
+	assert.True(t, b.Settings["syntax"].(bool))
+
+	b.Close()
+	s.Close()
+}
+
+func TestDiffManyLines(t *testing.T) {
+	// go-diff's DiffLinesToRunes doesn't encode the lines past the 10th one
+	// correctly, so changes there were missed
+	var lines []string
+	for i := 0; i < 20; i++ {
+		lines = append(lines, "line "+string(rune('a'+i)))
+	}
+	base := strings.Join(lines, "\n") + "\n"
+	lines[15] = "changed"
+	b := NewBufferFromString(strings.Join(lines, "\n")+"\n", "", BTDefault)
+	b.diffBase = []byte(base)
+	b.updateDiffSync()
+
+	for i := 0; i < 20; i++ {
+		if i == 15 {
+			assert.Equal(t, DiffStatus(DSModified), b.DiffStatus(i))
+		} else {
+			assert.Equal(t, DiffStatus(DSUnchanged), b.DiffStatus(i), "line %d", i)
+		}
+	}
+}
+
+func TestDiffBuffers(t *testing.T) {
+	a := NewBufferFromString("foo\nbar\nbaz\n", "", BTDefault)
+	b := NewBufferFromString("foo\nqux\nbaz\nquux\n", "", BTDefault)
+	DiffBuffers(a, b)
+
+	assert.True(t, a.Settings["diffgutter"].(bool))
+	assert.True(t, b.Settings["diffgutter"].(bool))
+
+	assert.Equal(t, DiffStatus(DSUnchanged), a.DiffStatus(0))
+	assert.Equal(t, DiffStatus(DSModified), a.DiffStatus(1))
+	assert.Equal(t, DiffStatus(DSUnchanged), a.DiffStatus(2))
+
+	assert.Equal(t, DiffStatus(DSModified), b.DiffStatus(1))
+	assert.Equal(t, DiffStatus(DSAdded), b.DiffStatus(3))
+}
+
+func TestMatchIndex(t *testing.T) {
+	b := NewBufferFromString("foo bar Foo\nbar\nfoofoo", "", BTDefault)
+
+	i, n, err := b.MatchIndex("foo", false, Loc{8, 0})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, i)
+	assert.Equal(t, 4, n)
+
+	i, n, _ = b.MatchIndex("foo", false, Loc{3, 2})
+	assert.Equal(t, 4, i)
+	assert.Equal(t, 4, n)
+
+	i, n, _ = b.MatchIndex("foo", false, Loc{1, 1})
+	assert.Equal(t, 0, i)
+	assert.Equal(t, 4, n)
+
+	b.Settings["ignorecase"] = false
+	i, n, _ = b.MatchIndex("foo", false, Loc{3, 2})
+	assert.Equal(t, 3, i)
+	assert.Equal(t, 3, n)
+
+	i, n, _ = b.MatchIndex("ba.", true, Loc{0, 1})
+	assert.Equal(t, 2, i)
+	assert.Equal(t, 2, n)
+
+	_, _, err = b.MatchIndex("(", true, Loc{0, 0})
+	assert.NotNil(t, err)
+
+	// the matches are counted again once the buffer is modified
+	b.Insert(Loc{0, 1}, "bar ")
+	i, n, _ = b.MatchIndex("ba.", true, Loc{4, 1})
+	assert.Equal(t, 3, i)
+	assert.Equal(t, 3, n)
+}
+
+func TestContentChanged(t *testing.T) {
+	var changed []string
+	OnContentChanged(func(b *Buffer) {
+		changed = append(changed, string(b.Bytes()))
+	})
+	defer func() { contentChangedCallbacks = nil }()
+
+	b := NewBufferFromString("foo", "", BTDefault)
+	b.Insert(Loc{3, 0}, " bar")
+	b.Remove(Loc{0, 0}, Loc{1, 0})
+
+	// both modifications are notified at once
+	select {
+	case sb := <-ContentChanged:
+		NotifyContentChanged(sb)
+	case <-time.After(10 * ContentChangedDelay):
+		t.Fatal("content change was not notified")
+	}
+	assert.Equal(t, []string{"oo bar"}, changed)
+
+	select {
+	case <-ContentChanged:
+		t.Fatal("content change was notified twice")
+	case <-time.After(2 * ContentChangedDelay):
+	}
+
+	b.Close()
+}
+
+func TestUndoLimit(t *testing.T) {
+	b := NewBufferFromString("", "", BTDefault)
+	b.Settings["undolimit"] = float64(3)
+	for _, s := range []string{"a", "b", "c", "d", "e"} {
+		b.Insert(b.End(), s)
+	}
+	assert.Equal(t, 3, b.UndoStack.Len())
+
+	for i := 0; i < 5; i++ {
+		b.UndoOneEvent()
+	}
+	assert.Equal(t, "ab", string(b.Bytes()))
+
+	b.RedoOneEvent()
+	assert.Equal(t, "abc", string(b.Bytes()))
+	b.Insert(b.End(), "f")
+	assert.Equal(t, 0, b.RedoStack.Len())
+
+	// past the limit, a tenth of the history is dropped
+	b.Settings["undolimit"] = float64(20)
+	for b.UndoStack.Len() < 20 {
+		b.Insert(b.End(), "g")
+	}
+	b.Insert(b.End(), "h")
+	assert.Equal(t, 18, b.UndoStack.Len())
+	b.Close()
+}
+
+func TestExpandSelection(t *testing.T) {
+	b := NewBufferFromString(strings.Join([]string{
+		"func f() {",
+		"\tif (a && b[1]) {",
+		"\t\tfoo(bar, baz)",
+		"\t}",
+		"}",
+	}, "\n"), "", BTDefault)
+
+	var got []string
+	sel := [2]Loc{{7, 2}, {7, 2}}
+	for {
+		next := b.ExpandSelection(sel)
+		if next == sel {
+			break
+		}
+		sel = next
+		got = append(got, string(b.Substr(sel[0], sel[1])))
+	}
+	assert.Equal(t, []string{
+		"bar",
+		"bar, baz",
+		"(bar, baz)",
+		"\t\tfoo(bar, baz)",
+		"\n\t\tfoo(bar, baz)\n\t",
+		"{\n\t\tfoo(bar, baz)\n\t}",
+		"\tif (a && b[1]) {\n\t\tfoo(bar, baz)\n\t}",
+		"\n\tif (a && b[1]) {\n\t\tfoo(bar, baz)\n\t}\n",
+		"{\n\tif (a && b[1]) {\n\t\tfoo(bar, baz)\n\t}\n}",
+		string(b.Bytes()),
+	}, got)
+
+	// a backwards selection of "1", with a bracket before it on the same line
+	sel = b.ExpandSelection([2]Loc{{13, 1}, {12, 1}})
+	assert.Equal(t, "[1]", string(b.Substr(sel[0], sel[1])))
+	sel = b.ExpandSelection(sel)
+	assert.Equal(t, "a && b[1]", string(b.Substr(sel[0], sel[1])))
+	sel = b.ExpandSelection(sel)
+	assert.Equal(t, "(a && b[1])", string(b.Substr(sel[0], sel[1])))
+}
+
+func TestTrimTrailingWhitespace(t *testing.T) {
+	b := NewBufferFromString("foo  \n\t\nbar\t \nbaz\n", "", BTDefault)
+	assert.Equal(t, 3, b.TrimTrailingWhitespace(-1))
+	assert.Equal(t, "foo\n\nbar\nbaz\n", string(b.Bytes()))
+	assert.Equal(t, 0, b.TrimTrailingWhitespace(-1))
+
+	b = NewBufferFromString("foo \nbar \n", "", BTDefault)
+	assert.Equal(t, 1, b.TrimTrailingWhitespace(1))
+	assert.Equal(t, "foo\nbar \n", string(b.Bytes()))
+}
+
+func TestSniffEncoding(t *testing.T) {
+	b := NewBufferFromString("\xef\xbb\xbfcaf\xc3\xa9\n", "", BTDefault)
+	assert.Equal(t, "utf-8", b.Settings["encoding"])
+	assert.Equal(t, "\ufeffcafé", b.Line(0))
+	assert.Equal(t, "\xef\xbb\xbfcaf\xc3\xa9\n", string(b.Bytes()))
+
+	b = NewBufferFromString("caf\xe9\n", "", BTDefault)
+	assert.Equal(t, "iso-8859-1", b.Settings["encoding"])
+	assert.Equal(t, "café", b.Line(0))
+
+	b = NewBufferFromString("\xff\xfeh\x00i\x00", "", BTDefault)
+	assert.Equal(t, "utf-16le", b.Settings["encoding"])
+	assert.Equal(t, "\ufeffhi", b.Line(0))
+
+	// the buffers created by micro are not sniffed
+	b = NewBufferFromString("caf\xe9\n", "", BTScratch)
+	assert.Equal(t, "utf-8", b.Settings["encoding"])
+}
+
+func TestOpensIndent(t *testing.T) {
+	b := NewBufferFromString("", "", BTDefault)
+	assert.True(t, b.OpensIndent([]byte("func f() {")))
+	assert.True(t, b.OpensIndent([]byte("\tfoo(  ")))
+	assert.False(t, b.OpensIndent([]byte("foo()")))
+	assert.False(t, b.OpensIndent([]byte("    ")))
+	assert.False(t, b.OpensIndent([]byte("case 1:")))
+
+	b.Settings["filetype"] = "python"
+	assert.True(t, b.OpensIndent([]byte("def f():")))
+}
+
+func TestClosesIndent(t *testing.T) {
+	assert.True(t, ClosesIndent([]byte("func f() {"), []byte("}")))
+	assert.True(t, ClosesIndent([]byte("\tfoo( "), []byte(" )")))
+	assert.False(t, ClosesIndent([]byte("foo("), []byte("]")))
+	assert.False(t, ClosesIndent([]byte("foo("), []byte("a)")))
+	assert.False(t, ClosesIndent([]byte("foo"), []byte(")")))
+	assert.False(t, ClosesIndent([]byte("{"), nil))
+}
+
+func TestNextDiffLine(t *testing.T) {
+	b := NewBufferFromString("a\nb\nc\nd\ne\n", "", BTDefault)
+	b.SetDiffBase([]byte("a\nb\nc\nd\ne\n"))
+	_, ok := b.NextDiffLine(0, true)
+	assert.False(t, ok)
+
+	b.SetDiffBase([]byte("a\nx\nc\nd\nx\n"))
+	l, ok := b.NextDiffLine(0, true)
+	assert.True(t, ok)
+	assert.Equal(t, 1, l)
+	l, _ = b.NextDiffLine(1, true)
+	assert.Equal(t, 4, l)
+	l, _ = b.NextDiffLine(4, true)
+	assert.Equal(t, 1, l)
+
+	l, _ = b.NextDiffLine(4, false)
+	assert.Equal(t, 1, l)
+	l, _ = b.NextDiffLine(1, false)
+	assert.Equal(t, 4, l)
+}
+
+func TestBookmarks(t *testing.T) {
+	b := NewBufferFromString("a\nb\nc\nd\ne\n", "", BTDefault)
+	_, ok := b.NextBookmark(0, true)
+	assert.False(t, ok)
+
+	assert.True(t, b.ToggleBookmark(3))
+	assert.True(t, b.ToggleBookmark(1))
+	assert.Equal(t, []int{1, 3}, b.Bookmarks())
+	l, _ := b.NextBookmark(1, true)
+	assert.Equal(t, 3, l)
+	l, _ = b.NextBookmark(3, true)
+	assert.Equal(t, 1, l)
+	l, _ = b.NextBookmark(1, false)
+	assert.Equal(t, 3, l)
+
+	// inserting lines above moves the bookmarks down
+	b.Insert(Loc{0, 1}, "x\ny\n")
+	assert.Equal(t, []int{3, 5}, b.Bookmarks())
+	// splitting a line keeps the bookmark on its start
+	b.Insert(Loc{1, 3}, "\n")
+	assert.Equal(t, []int{3, 6}, b.Bookmarks())
+	// removing lines moves the bookmarks up, the ones on the removed lines
+	// are merged
+	b.Remove(Loc{0, 2}, Loc{0, 6})
+	assert.Equal(t, []int{2}, b.Bookmarks())
+
+	assert.False(t, b.ToggleBookmark(2))
+	assert.Empty(t, b.Bookmarks())
+}
+
+func TestSymbols(t *testing.T) {
+	b := NewBufferFromString("package main\n\ntype T struct{}\n\nfunc (t *T) Run() {\n}\n\nfunc main() {\n\tvar x int\n}\n", "", BTDefault)
+	assert.Nil(t, b.Symbols())
+
+	b.Settings["filetype"] = "go"
+	assert.Equal(t, []Symbol{{"T", 2, 0}, {"Run", 4, 0}, {"main", 7, 0}}, b.Symbols())
+
+	// grouped declarations, without the fields of their types
+	b = NewBufferFromString("package main\n\nconst (\n\t// A is a\n\tA = iota\n\tB\n)\n\ntype (\n\tT struct {\n\t\tx int\n\t}\n\tU int\n)\n\nvar (\n\tv, w int\n)\n", "", BTDefault)
+	b.Settings["filetype"] = "go"
+	assert.Equal(t, []Symbol{{"A", 4, 0}, {"B", 5, 0}, {"T", 9, 0}, {"U", 12, 0}, {"v", 16, 0}}, b.Symbols())
+
+	b = NewBufferFromString("class A:\n    def f(self):\n        pass\n\nasync def g():\n    pass\n", "", BTDefault)
+	b.Settings["filetype"] = "python"
+	assert.Equal(t, []Symbol{{"A", 0, 0}, {"f", 1, 1}, {"g", 4, 0}}, b.Symbols())
+
+	b = NewBufferFromString("# Title\n\n## Usage\n\n```sh\n# not a heading\n```\n\n### Options\n## Notes\n", "", BTDefault)
+	b.Settings["filetype"] = "markdown"
+	assert.Equal(t, []Symbol{{"Title", 0, 0}, {"Usage", 2, 1}, {"Options", 8, 2}, {"Notes", 9, 1}}, b.Symbols())
+}
+
+func TestNextMessageLine(t *testing.T) {
+	b := NewBufferFromString("a\nb\nc\nd\ne\n", "", BTDefault)
+	_, ok := b.NextMessageLine(0, true)
+	assert.False(t, ok)
+
+	b.AddMessage(NewMessageAtLine("test", "warning", 4, MTWarning))
+	b.AddMessage(NewMessageAtLine("test", "error", 2, MTError))
+	l, ok := b.NextMessageLine(0, true)
+	assert.True(t, ok)
+	assert.Equal(t, 1, l)
+	l, _ = b.NextMessageLine(1, true)
+	assert.Equal(t, 3, l)
+	l, _ = b.NextMessageLine(3, true)
+	assert.Equal(t, 1, l)
+	l, _ = b.NextMessageLine(1, false)
+	assert.Equal(t, 3, l)
+	l, _ = b.NextMessageLine(3, false)
+	assert.Equal(t, 1, l)
+
+	b.ClearMessages("test")
+	_, ok = b.NextMessageLine(0, true)
+	assert.False(t, ok)
+}
+
+func TestBracketDepths(t *testing.T) {
+	b := NewBufferFromString("f(a[b], {\nc})\n)(]\n", "", BTDefault)
+
+	assert.Equal(t, map[int]int{1: 0, 3: 1, 5: 1, 8: 1}, b.BracketDepths(0))
+	assert.Equal(t, map[int]int{1: 1, 2: 0}, b.BracketDepths(1))
+	// unmatched closing brackets don't change the depth of the others
+	assert.Equal(t, map[int]int{0: -1, 1: 0, 2: -1}, b.BracketDepths(2))
+
+	b.Insert(Loc{0, 0}, "(")
+	assert.Equal(t, map[int]int{1: 2, 2: 1}, b.BracketDepths(1))
+	assert.Equal(t, map[int]int{0: 0, 1: 0, 2: -1}, b.BracketDepths(2))
+}
+
+func TestReplaceArg(t *testing.T) {
+	b := NewBufferFromString("open fo", "", BTInfo)
+	b.GetActiveCursor().GotoLoc(b.End())
+	complete := func(b *Buffer) ([]string, []string) {
+		return b.ReplaceArg([]string{"a/foo", "b/foo"})
+	}
+
+	assert.True(t, b.Autocomplete(complete))
+	assert.Equal(t, "open a/foo", string(b.Bytes()))
+	b.CycleAutocomplete(true)
+	assert.Equal(t, "open b/foo", string(b.Bytes()))
+	// the typed argument comes back after the last suggestion
+	b.CycleAutocomplete(true)
+	assert.Equal(t, "open fo", string(b.Bytes()))
+	b.CycleAutocomplete(false)
+	assert.Equal(t, "open b/foo", string(b.Bytes()))
+}
+
+func TestSize(t *testing.T) {
+	b := NewBufferFromString("ab\ncd\n", "", BTDefault)
+	assert.Equal(t, 6, b.Size())
+
+	// the cached size follows the edits and the line endings
+	b.Insert(Loc{0, 1}, "x\ny")
+	assert.Equal(t, 9, b.Size())
+	b.Remove(Loc{0, 0}, Loc{0, 1})
+	assert.Equal(t, 6, b.Size())
+	b.SetOptionNative("fileformat", "dos")
+	assert.Equal(t, 8, b.Size())
+}
@@ -0,0 +1,51 @@
+package buffer
+
+import "time"
+
+// ContentChangedDelay is how long a buffer must stay unmodified before its
+// content changed callbacks are run, so that they don't run on every keystroke
+const ContentChangedDelay = 300 * time.Millisecond
+
+// ContentChanged receives the buffers whose content has changed, once they
+// have stayed unmodified for ContentChangedDelay. The main loop reads it and
+// calls NotifyContentChanged
+var ContentChanged = make(chan *SharedBuffer)
+
+var contentChangedCallbacks []func(b *Buffer)
+
+// OnContentChanged registers a callback which is run on the main loop when
+// the content of a buffer changes. Successive modifications are debounced
+// into a single call, made with the new content already in the buffer. This
+// lets features such as word counts or diagnostics stay up to date without
+// polling. The callback should be quick since it delays event handling
+func OnContentChanged(cb func(b *Buffer)) {
+	contentChangedCallbacks = append(contentChangedCallbacks, cb)
+}
+
+// NotifyContentChanged runs the content changed callbacks for an open buffer
+// backed by the given shared buffer
+func NotifyContentChanged(sb *SharedBuffer) {
+	for _, b := range OpenBuffers {
+		if b.SharedBuffer == sb {
+			for _, cb := range contentChangedCallbacks {
+				cb(b)
+			}
+			return
+		}
+	}
+}
+
+// contentModified (re)starts the timer after which the content changed
+// callbacks are notified
+func (b *SharedBuffer) contentModified() {
+	if len(contentChangedCallbacks) == 0 {
+		return
+	}
+	if b.contentTimer == nil {
+		b.contentTimer = time.AfterFunc(ContentChangedDelay, func() {
+			ContentChanged <- b
+		})
+	} else {
+		b.contentTimer.Reset(ContentChangedDelay)
+	}
+}
@@ -193,6 +193,26 @@ func (c *Cursor) Deselect(start bool) {
 	}
 }
 
+// DuplicateSelection inserts a copy of the selected text right after
+// the selection, and selects the copy
+func (c *Cursor) DuplicateSelection() {
+	if !c.HasSelection() {
+		return
+	}
+	end := c.CurSelection[1]
+	if c.CurSelection[0].GreaterThan(end) {
+		end = c.CurSelection[0]
+	}
+
+	sel := c.GetSelection()
+	c.buf.Insert(end, string(sel))
+
+	c.SetSelectionStart(end)
+	c.Loc = end.Move(util.CharacterCount(sel), c.buf)
+	c.SetSelectionEnd(c.Loc)
+	c.StoreVisualX()
+}
+
 // GetSelection returns the cursor's selection
 func (c *Cursor) GetSelection() []byte {
 	if InBounds(c.CurSelection[0], c.buf) && InBounds(c.CurSelection[1], c.buf) {
@@ -355,6 +375,32 @@ func (c *Cursor) SelectWord() {
 	c.Loc = c.CurSelection[1]
 }
 
+// SelectParagraph selects the paragraph the cursor is currently on, that
+// is the lines around it up to the surrounding empty lines, including the
+// newline of the last line. Nothing is selected on an empty line
+func (c *Cursor) SelectParagraph() {
+	if len(c.buf.LineBytes(c.Y)) == 0 {
+		return
+	}
+
+	start, end := c.Y, c.Y
+	for start > 0 && len(c.buf.LineBytes(start-1)) != 0 {
+		start--
+	}
+	for end < c.buf.LinesNum()-1 && len(c.buf.LineBytes(end+1)) != 0 {
+		end++
+	}
+
+	c.SetSelectionStart(Loc{0, start})
+	if end < c.buf.LinesNum()-1 {
+		c.SetSelectionEnd(Loc{0, end + 1})
+	} else {
+		c.SetSelectionEnd(c.buf.End())
+	}
+	c.OrigSelection = c.CurSelection
+	c.Loc = c.CurSelection[1]
+}
+
 // AddWordToSelection adds the word the cursor is currently on
 // to the selection
 func (c *Cursor) AddWordToSelection() {
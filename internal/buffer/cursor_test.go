@@ -0,0 +1,97 @@
+package buffer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDuplicateSelection(t *testing.T) {
+	b := NewBufferFromString("foo bar baz", "", BTDefault)
+	c := b.GetActiveCursor()
+	c.SetSelectionStart(Loc{4, 0})
+	c.SetSelectionEnd(Loc{7, 0})
+	c.DuplicateSelection()
+
+	assert.Equal(t, "foo barbar baz", string(b.Bytes()))
+	assert.Equal(t, "bar", string(c.GetSelection()))
+	assert.Equal(t, [2]Loc{{7, 0}, {10, 0}}, c.CurSelection)
+	assert.Equal(t, Loc{10, 0}, c.Loc)
+}
+
+func TestDuplicateSelectionMultiline(t *testing.T) {
+	b := NewBufferFromString("one\ntwo\nthree", "", BTDefault)
+	c := b.GetActiveCursor()
+	// selected backwards, from the middle of "two" to the start of "one"
+	c.SetSelectionStart(Loc{2, 1})
+	c.SetSelectionEnd(Loc{0, 0})
+	c.DuplicateSelection()
+
+	assert.Equal(t, "one\ntwone\ntwo\nthree", string(b.Bytes()))
+	assert.Equal(t, "one\ntw", string(c.GetSelection()))
+	assert.Equal(t, [2]Loc{{2, 1}, {2, 2}}, c.CurSelection)
+}
+
+func TestSelectWord(t *testing.T) {
+	b := NewBufferFromString("foo(bar_baz, 42);", "", BTDefault)
+	c := b.GetActiveCursor()
+
+	c.GotoLoc(Loc{6, 0})
+	c.SelectWord()
+	assert.Equal(t, "bar_baz", string(c.GetSelection()))
+	assert.Equal(t, Loc{11, 0}, c.Loc)
+
+	c.ResetSelection()
+	c.GotoLoc(Loc{3, 0})
+	c.SelectWord()
+	assert.Equal(t, "(", string(c.GetSelection()))
+
+	c.ResetSelection()
+	c.GotoLoc(Loc{0, 0})
+	c.SelectWord()
+	assert.Equal(t, "foo", string(c.GetSelection()))
+
+	c.ResetSelection()
+	c.GotoLoc(Loc{14, 0})
+	c.SelectWord()
+	assert.Equal(t, "42", string(c.GetSelection()))
+}
+
+func TestSelectLine(t *testing.T) {
+	b := NewBufferFromString("one\ntwo\nthree", "", BTDefault)
+	c := b.GetActiveCursor()
+
+	c.GotoLoc(Loc{1, 1})
+	c.SelectLine()
+	assert.Equal(t, "two\n", string(c.GetSelection()))
+
+	c.ResetSelection()
+	c.GotoLoc(Loc{2, 2})
+	c.SelectLine()
+	assert.Equal(t, "three", string(c.GetSelection()))
+}
+
+func TestSelectParagraph(t *testing.T) {
+	b := NewBufferFromString("one\ntwo\n\nthree\nfour\n\nfive", "", BTDefault)
+	c := b.GetActiveCursor()
+
+	c.GotoLoc(Loc{1, 1})
+	c.SelectParagraph()
+	assert.Equal(t, "one\ntwo\n", string(c.GetSelection()))
+	assert.Equal(t, Loc{0, 2}, c.Loc)
+
+	c.ResetSelection()
+	c.GotoLoc(Loc{0, 3})
+	c.SelectParagraph()
+	assert.Equal(t, "three\nfour\n", string(c.GetSelection()))
+
+	c.ResetSelection()
+	c.GotoLoc(Loc{0, 6})
+	c.SelectParagraph()
+	assert.Equal(t, "five", string(c.GetSelection()))
+
+	c.ResetSelection()
+	c.GotoLoc(Loc{0, 2})
+	c.SelectParagraph()
+	assert.False(t, c.HasSelection())
+}
@@ -240,6 +240,11 @@ func (eh *EventHandler) Execute(t *TextEvent) {
 		eh.RedoStack = new(TEStack)
 	}
 	eh.UndoStack.Push(t)
+	if limit := util.IntOpt(eh.buf.Settings["undolimit"]); limit > 0 && eh.UndoStack.Len() > limit {
+		// a tenth of the history is forgotten at once, so that the stack
+		// isn't walked again on every edit once the limit is reached
+		eh.UndoStack.Trim(limit - limit/10)
+	}
 
 	b, err := config.RunPluginFnBool(nil, "onBeforeTextEvent", luar.New(ulua.L, eh.buf), luar.New(ulua.L, t))
 	if err != nil {
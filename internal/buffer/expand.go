@@ -0,0 +1,93 @@
+package buffer
+
+import (
+	"github.com/zyedidia/micro/v2/internal/util"
+)
+
+// ExpandSelection returns the smallest region strictly containing the given
+// selection among: the word under it, the lines it spans, the inside of the
+// enclosing brackets, the enclosing brackets themselves and the whole
+// buffer. It returns the selection unchanged if it already spans the whole
+// buffer
+func (b *Buffer) ExpandSelection(sel [2]Loc) [2]Loc {
+	if sel[1].LessThan(sel[0]) {
+		sel[0], sel[1] = sel[1], sel[0]
+	}
+
+	candidates := [][2]Loc{
+		{Loc{0, sel[0].Y}, Loc{util.CharacterCount(b.LineBytes(sel[1].Y)), sel[1].Y}},
+		{b.Start(), b.End()},
+	}
+	if word, ok := b.wordAt(sel[0]); ok {
+		candidates = append(candidates, word)
+	}
+	if open, close, ok := b.enclosingBrackets(sel); ok {
+		candidates = append(candidates,
+			[2]Loc{open.Move(1, b), close},
+			[2]Loc{open, close.Move(1, b)})
+	}
+
+	best, size := sel, -1
+	for _, c := range candidates {
+		if c[0].GreaterThan(sel[0]) || c[1].LessThan(sel[1]) || c == sel {
+			continue
+		}
+		if d := c[0].Diff(c[1], b); size < 0 || d < size {
+			best, size = c, d
+		}
+	}
+	return best
+}
+
+// wordAt returns the region of the word at (or right before) the given
+// location
+func (b *Buffer) wordAt(loc Loc) ([2]Loc, bool) {
+	l := []rune(string(b.LineBytes(loc.Y)))
+	x := loc.X
+	if x >= len(l) || !util.IsWordChar(l[x]) {
+		x--
+	}
+	if x < 0 || x >= len(l) || !util.IsWordChar(l[x]) {
+		return [2]Loc{}, false
+	}
+
+	start, end := x, x+1
+	for start > 0 && util.IsWordChar(l[start-1]) {
+		start--
+	}
+	for end < len(l) && util.IsWordChar(l[end]) {
+		end++
+	}
+	return [2]Loc{{start, loc.Y}, {end, loc.Y}}, true
+}
+
+// enclosingBrackets returns the locations of the innermost pair of brackets
+// (of BracePairs) containing the given selection
+func (b *Buffer) enclosingBrackets(sel [2]Loc) (Loc, Loc, bool) {
+	depth := make([]int, len(BracePairs))
+	for y := sel[0].Y; y >= 0; y-- {
+		l := []rune(string(b.LineBytes(y)))
+		x := len(l) - 1
+		if y == sel[0].Y {
+			x = util.Min(sel[0].X, len(l)) - 1
+		}
+		for ; x >= 0; x-- {
+			for i, pair := range BracePairs {
+				if l[x] == pair[1] {
+					depth[i]++
+				} else if l[x] == pair[0] {
+					if depth[i] > 0 {
+						depth[i]--
+						continue
+					}
+					open := Loc{x, y}
+					close, _, found := b.FindMatchingBrace(pair, open)
+					if found && close.GreaterEqual(sel[1]) {
+						return open, close, true
+					}
+				}
+			}
+		}
+	}
+	return Loc{}, Loc{}, false
+}
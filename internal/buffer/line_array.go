@@ -71,10 +71,13 @@ type FileFormat byte
 // A LineArray simply stores and array of lines and makes it easy to insert
 // and delete in it
 type LineArray struct {
-	lines    []Line
-	Endings  FileFormat
-	initsize uint64
-	lock     sync.Mutex
+	lines   []Line
+	Endings FileFormat
+	// MixedEndings is true if the line endings were autodetected and both
+	// LF and CRLF endings were found, in which case Endings is FFUnix
+	MixedEndings bool
+	initsize     uint64
+	lock         sync.Mutex
 }
 
 // Append efficiently appends lines together
@@ -105,6 +108,8 @@ func NewLineArray(size uint64, endings FileFormat, reader io.Reader) *LineArray
 	var loaded int
 
 	la.Endings = endings
+	auto := endings == FFAuto
+	var seenLF, seenCRLF bool
 
 	n := 0
 	for {
@@ -120,10 +125,13 @@ func NewLineArray(size uint64, endings FileFormat, reader io.Reader) *LineArray
 				la.Endings = FFDos
 			}
 			dlen = len(data)
+			seenCRLF = true
 		} else if dlen > 0 {
 			if la.Endings == FFAuto {
 				la.Endings = FFUnix
 			}
+			// the last line may have no newline at all
+			seenLF = seenLF || data[dlen-1] == '\n'
 		}
 
 		// If we are loading a large file (greater than 1000) we use the file
@@ -164,6 +172,11 @@ func NewLineArray(size uint64, endings FileFormat, reader io.Reader) *LineArray
 		n++
 	}
 
+	if auto && seenLF && seenCRLF {
+		la.Endings = FFUnix
+		la.MixedEndings = true
+	}
+
 	return la
 }
 
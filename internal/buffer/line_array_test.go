@@ -59,3 +59,19 @@ func TestRemove(t *testing.T) {
 	bytes := la.Bytes()
 	assert.Equal(t, unicode_txt, string(bytes))
 }
+
+func TestLineEndings(t *testing.T) {
+	la := NewLineArray(0, FFAuto, strings.NewReader("foo\r\nbar\r\nbaz"))
+	assert.Equal(t, FileFormat(FFDos), la.Endings)
+	assert.False(t, la.MixedEndings)
+	assert.Equal(t, "foo\r\nbar\r\nbaz", string(la.Bytes()))
+
+	la = NewLineArray(0, FFAuto, strings.NewReader("foo\r\nbar\nbaz\r\n"))
+	assert.Equal(t, FileFormat(FFUnix), la.Endings)
+	assert.True(t, la.MixedEndings)
+	assert.Equal(t, "foo\nbar\nbaz\n", string(la.Bytes()))
+
+	la = NewLineArray(0, FFDos, strings.NewReader("foo\nbar\r\n"))
+	assert.Equal(t, FileFormat(FFDos), la.Endings)
+	assert.False(t, la.MixedEndings)
+}
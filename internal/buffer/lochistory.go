@@ -0,0 +1,68 @@
+package buffer
+
+// LocHistoryMax is the maximum number of entries kept in a location history
+const LocHistoryMax = 100
+
+// A Position is a location in a given file
+type Position struct {
+	Path string
+	Loc  Loc
+}
+
+// LocHistory stores the significant locations the user has visited
+// (jumps and buffer switches), so they can be traversed back and forth
+// like a browser history
+type LocHistory struct {
+	entries []Position
+	// current is the index of the entry the user is at. It is equal to
+	// len(entries) when the user is not traversing the history
+	current int
+}
+
+// Len returns the number of entries in the history
+func (h *LocHistory) Len() int {
+	return len(h.entries)
+}
+
+// Record adds the position which is being left to the history.
+// Any forward history is discarded.
+func (h *LocHistory) Record(p Position) {
+	if p.Path == "" {
+		return
+	}
+	h.entries = h.entries[:h.current]
+	if n := len(h.entries); n > 0 && h.entries[n-1] == p {
+		h.current = n
+		return
+	}
+	h.entries = append(h.entries, p)
+	if len(h.entries) > LocHistoryMax {
+		h.entries = h.entries[len(h.entries)-LocHistoryMax:]
+	}
+	h.current = len(h.entries)
+}
+
+// Back returns the position preceding the current one. cur is the position
+// the user is currently at, and is remembered so that Forward can return to it.
+func (h *LocHistory) Back(cur Position) (Position, bool) {
+	if h.current == 0 {
+		return Position{}, false
+	}
+	if h.current == len(h.entries) && cur.Path != "" && h.entries[h.current-1] != cur {
+		h.entries = append(h.entries, cur)
+	}
+	h.current--
+	if h.entries[h.current] == cur && h.current > 0 {
+		h.current--
+	}
+	return h.entries[h.current], true
+}
+
+// Forward returns the position following the current one
+func (h *LocHistory) Forward() (Position, bool) {
+	if h.current >= len(h.entries)-1 {
+		return Position{}, false
+	}
+	h.current++
+	return h.entries[h.current], true
+}
@@ -0,0 +1,69 @@
+package buffer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocHistory(t *testing.T) {
+	a := Position{"a.go", Loc{0, 10}}
+	b := Position{"b.go", Loc{4, 2}}
+	c := Position{"a.go", Loc{0, 200}}
+	d := Position{"c.go", Loc{0, 0}}
+
+	h := new(LocHistory)
+	_, ok := h.Back(a)
+	assert.False(t, ok)
+
+	// a -> b (buffer switch) -> c (jump back in the first buffer)
+	h.Record(a)
+	h.Record(b)
+
+	p, ok := h.Back(c)
+	assert.True(t, ok)
+	assert.Equal(t, b, p)
+	p, ok = h.Back(b)
+	assert.True(t, ok)
+	assert.Equal(t, a, p)
+	_, ok = h.Back(a)
+	assert.False(t, ok)
+
+	p, ok = h.Forward()
+	assert.True(t, ok)
+	assert.Equal(t, b, p)
+	p, ok = h.Forward()
+	assert.True(t, ok)
+	assert.Equal(t, c, p)
+	_, ok = h.Forward()
+	assert.False(t, ok)
+
+	// recording after going back discards the forward history
+	h.Back(c)
+	h.Back(b)
+	h.Record(a)
+	_, ok = h.Forward()
+	assert.False(t, ok)
+	p, ok = h.Back(d)
+	assert.True(t, ok)
+	assert.Equal(t, a, p)
+	p, ok = h.Forward()
+	assert.True(t, ok)
+	assert.Equal(t, d, p)
+}
+
+func TestLocHistoryRecord(t *testing.T) {
+	h := new(LocHistory)
+	h.Record(Position{"", Loc{0, 0}})
+	assert.Equal(t, 0, h.Len())
+
+	a := Position{"a.go", Loc{0, 1}}
+	h.Record(a)
+	h.Record(a)
+	assert.Equal(t, 1, h.Len())
+
+	for i := 0; i < 2*LocHistoryMax; i++ {
+		h.Record(Position{"a.go", Loc{0, i}})
+	}
+	assert.Equal(t, LocHistoryMax, h.Len())
+}
@@ -61,6 +61,55 @@ func (m *Message) Style() tcell.Style {
 	return config.DefStyle
 }
 
+// Glyph returns the character drawn before the '>' marking the message in
+// the gutter: E for errors, W for warnings and '>' for the others
+func (m *Message) Glyph() rune {
+	switch m.Kind {
+	case MTError:
+		return 'E'
+	case MTWarning:
+		return 'W'
+	}
+	return '>'
+}
+
+// NextMessageLine returns the first line after (or before, if forward is
+// false) line y which has a gutter message, wrapping around the buffer. It
+// returns false if there are no messages
+func (b *Buffer) NextMessageLine(y int, forward bool) (int, bool) {
+	best, first, found := -1, -1, false
+	for _, m := range b.Messages {
+		l := m.Start.Y
+		if l < 0 || l >= b.LinesNum() {
+			continue
+		}
+		if forward {
+			if l > y && (best < 0 || l < best) {
+				best = l
+			}
+			if first < 0 || l < first {
+				first = l
+			}
+		} else {
+			if l < y && (best < 0 || l > best) {
+				best = l
+			}
+			if first < 0 || l > first {
+				first = l
+			}
+		}
+		found = true
+	}
+	if !found {
+		return 0, false
+	}
+	if best < 0 {
+		// wrap around
+		best = first
+	}
+	return best, true
+}
+
 func (b *Buffer) AddMessage(m *Message) {
 	b.Messages = append(b.Messages, m)
 }
@@ -0,0 +1,84 @@
+package buffer
+
+import (
+	"strings"
+	"sync"
+)
+
+// A bracketCache holds the brackets (of BracePairs) left open at the start of
+// the first lines of a buffer, so that the nesting depth of the brackets of a
+// line is known without scanning the buffer from its start on every redraw
+type bracketCache struct {
+	sync.Mutex
+	// open[y] are the open brackets at the start of line y, innermost last
+	open []string
+}
+
+// resetBrackets forgets the open brackets after line y, when it is modified
+// or highlighted again
+func (b *SharedBuffer) resetBrackets(y int) {
+	b.brackets.Lock()
+	defer b.brackets.Unlock()
+	if y < 0 {
+		y = 0
+	}
+	if y+1 < len(b.brackets.open) {
+		b.brackets.open = b.brackets.open[:y+1]
+	}
+}
+
+// BracketDepths returns the nesting depth of the brackets of line y, by
+// character index, counted from 0 for the outermost ones. Brackets in strings
+// and comments are ignored, and closing brackets which don't match the
+// innermost open bracket get -1
+func (b *Buffer) BracketDepths(y int) map[int]int {
+	c := &b.brackets
+	c.Lock()
+	if len(c.open) == 0 {
+		c.open = append(c.open, "")
+	}
+	for len(c.open) <= y {
+		n := len(c.open) - 1
+		c.open = append(c.open, b.scanBrackets(n, c.open[n], nil))
+	}
+	open := c.open[y]
+	c.Unlock()
+
+	depths := make(map[int]int)
+	b.scanBrackets(y, open, depths)
+	return depths
+}
+
+// scanBrackets returns the brackets still open at the end of line y given
+// the ones open at its start, and fills depths if it is not nil
+func (b *Buffer) scanBrackets(y int, open string, depths map[int]int) string {
+	match := b.Match(y)
+	skip := false
+	for x, r := range []rune(string(b.LineBytes(y))) {
+		if g, ok := match[x]; ok {
+			name := g.String()
+			skip = strings.HasPrefix(name, "comment") || strings.HasPrefix(name, "constant.string")
+		}
+		if skip {
+			continue
+		}
+		for _, bp := range BracePairs {
+			if r == bp[0] {
+				if depths != nil {
+					depths[x] = len(open)
+				}
+				open += string(r)
+			} else if r == bp[1] {
+				if len(open) > 0 && rune(open[len(open)-1]) == bp[0] {
+					open = open[:len(open)-1]
+					if depths != nil {
+						depths[x] = len(open)
+					}
+				} else if depths != nil {
+					depths[x] = -1
+				}
+			}
+		}
+	}
+	return open
+}
@@ -2,7 +2,6 @@ package buffer
 
 import (
 	"bufio"
-	"bytes"
 	"errors"
 	"io"
 	"os"
@@ -10,14 +9,15 @@ import (
 	"os/signal"
 	"path/filepath"
 	"runtime"
-	"unicode"
 
 	"github.com/zyedidia/micro/v2/internal/config"
+	ulua "github.com/zyedidia/micro/v2/internal/lua"
 	"github.com/zyedidia/micro/v2/internal/screen"
 	"github.com/zyedidia/micro/v2/internal/util"
 	"golang.org/x/text/encoding"
 	"golang.org/x/text/encoding/htmlindex"
 	"golang.org/x/text/transform"
+	luar "layeh.com/gopher-luar"
 )
 
 // LargeFileThreshold is the number of bytes when fastdirty is forced
@@ -93,8 +93,13 @@ func (b *Buffer) Save() error {
 	return b.SaveAs(b.Path)
 }
 
-// AutoSave saves the buffer to its default path
+// AutoSave saves the buffer to its default path. Buffers which are not
+// modified, have no path or cannot be saved are skipped, as well as files
+// modified by another program, which would be overwritten without asking
 func (b *Buffer) AutoSave() error {
+	if !b.Modified() || b.Path == "" || b.Type != BTDefault || b.ExternallyModified() {
+		return nil
+	}
 	return b.saveToFile(b.Path, false, true)
 }
 
@@ -111,6 +116,63 @@ func (b *Buffer) SaveAsWithSudo(filename string) error {
 	return b.saveToFile(filename, true, false)
 }
 
+// missesFinalNewline returns whether the buffer has some content but does
+// not end with a newline
+func (b *SharedBuffer) missesFinalNewline() bool {
+	return len(b.LineBytes(b.LinesNum()-1)) > 0
+}
+
+// fixFinalNewline adds or removes the newline at the end of the buffer,
+// according to the finalnewline and eofnewline options
+func (b *Buffer) fixFinalNewline() {
+	if !b.Settings["eofnewline"].(bool) {
+		// the content is written as is
+		return
+	}
+	mode := b.Settings["finalnewline"].(string)
+	if mode == "preserve" {
+		// only files which existed without a final newline are trimmed,
+		// new and empty files get one
+		if b.noEOFNewline {
+			mode = "trim"
+		} else {
+			mode = "ensure"
+		}
+	}
+
+	switch mode {
+	case "ensure":
+		end := b.End()
+		if b.RuneAt(Loc{end.X - 1, end.Y}) != '\n' {
+			b.insert(end, []byte{'\n'})
+		}
+	case "trim":
+		y := b.LinesNum() - 1
+		for y > 0 && len(b.LineBytes(y)) == 0 {
+			y--
+		}
+		if y < b.LinesNum()-1 {
+			b.Remove(Loc{util.CharacterCount(b.LineBytes(y)), y}, b.End())
+			b.RelocateCursors()
+		}
+	}
+}
+
+// writeSaveBackup copies the content of the given file, if it exists, to the
+// same path followed by a tilde. Files which are not writable are skipped,
+// since they are saved with sudo or not at all
+func writeSaveBackup(name string) error {
+	info, err := os.Stat(name)
+	if err != nil || !info.Mode().IsRegular() || info.Mode().Perm()&0200 == 0 {
+		return nil
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(name+"~", data, info.Mode().Perm())
+}
+
 func (b *Buffer) saveToFile(filename string, withSudo bool, autoSave bool) error {
 	var err error
 	if b.Type.Readonly {
@@ -123,24 +185,17 @@ func (b *Buffer) saveToFile(filename string, withSudo bool, autoSave bool) error
 		return errors.New("Save with sudo not supported on Windows")
 	}
 
-	if !autoSave && b.Settings["rmtrailingws"].(bool) {
-		for i, l := range b.lines {
-			leftover := util.CharacterCount(bytes.TrimRightFunc(l.data, unicode.IsSpace))
-
-			linelen := util.CharacterCount(l.data)
-			b.Remove(Loc{leftover, i}, Loc{linelen, i})
-		}
-
-		b.RelocateCursors()
+	// plugins may still change the buffer, e.g. to format it
+	if perr := config.RunPluginFn("preBufferSave", luar.New(ulua.L, b)); perr != nil {
+		screen.TermMessage(perr)
 	}
 
-	if b.Settings["eofnewline"].(bool) {
-		end := b.End()
-		if b.RuneAt(Loc{end.X - 1, end.Y}) != '\n' {
-			b.insert(end, []byte{'\n'})
-		}
+	if !autoSave && b.Settings["rmtrailingws"].(bool) {
+		b.TrimTrailingWhitespace(-1)
 	}
 
+	b.fixFinalNewline()
+
 	// Update the last time this file was updated after saving
 	defer func() {
 		b.ModTime, _ = util.GetModTime(filename)
@@ -204,6 +259,11 @@ func (b *Buffer) saveToFile(filename string, withSudo bool, autoSave bool) error
 		return
 	}
 
+	b.SaveBackupErr = nil
+	if b.Settings["savebackup"].(bool) && !withSudo {
+		b.SaveBackupErr = writeSaveBackup(absFilename)
+	}
+
 	if err = overwriteFile(absFilename, enc, fwriter, withSudo); err != nil {
 		return err
 	}
@@ -222,5 +282,10 @@ func (b *Buffer) saveToFile(filename string, withSudo bool, autoSave bool) error
 	b.AbsPath = absPath
 	b.isModified = false
 	b.UpdateRules()
+	b.saveBookmarks()
+
+	if perr := config.RunPluginFn("onBufferSave", luar.New(ulua.L, b)); perr != nil {
+		screen.TermMessage(perr)
+	}
 	return err
 }
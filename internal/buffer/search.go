@@ -2,6 +2,7 @@ package buffer
 
 import (
 	"regexp"
+	"sort"
 
 	"github.com/zyedidia/micro/v2/internal/util"
 )
@@ -103,6 +104,18 @@ func (b *Buffer) findUp(r *regexp.Regexp, start, end Loc) ([2]Loc, bool) {
 	return [2]Loc{}, false
 }
 
+// searchRegexp compiles the regular expression used to search for a given
+// string, according to the ignorecase setting
+func (b *Buffer) searchRegexp(s string, useRegex bool) (*regexp.Regexp, error) {
+	if !useRegex {
+		s = regexp.QuoteMeta(s)
+	}
+	if b.Settings["ignorecase"].(bool) {
+		s = "(?i)" + s
+	}
+	return regexp.Compile(s)
+}
+
 // FindNext finds the next occurrence of a given string in the buffer
 // It returns the start and end location of the match (if found) and
 // a boolean indicating if it was found
@@ -112,19 +125,7 @@ func (b *Buffer) FindNext(s string, start, end, from Loc, down bool, useRegex bo
 		return [2]Loc{}, false, nil
 	}
 
-	var r *regexp.Regexp
-	var err error
-
-	if !useRegex {
-		s = regexp.QuoteMeta(s)
-	}
-
-	if b.Settings["ignorecase"].(bool) {
-		r, err = regexp.Compile("(?i)" + s)
-	} else {
-		r, err = regexp.Compile(s)
-	}
-
+	r, err := b.searchRegexp(s, useRegex)
 	if err != nil {
 		return [2]Loc{}, false, err
 	}
@@ -145,6 +146,47 @@ func (b *Buffer) FindNext(s string, start, end, from Loc, down bool, useRegex bo
 	return l, found, nil
 }
 
+// A matchCache holds the start of every match of a search in the buffer, so
+// that MatchIndex doesn't scan the whole buffer again until it is modified
+type matchCache struct {
+	regexp string
+	locs   []Loc
+}
+
+// MatchIndex returns the position (starting at 1) of the match starting at
+// the given location among all the occurrences of a given string in the
+// buffer, along with the total number of occurrences
+func (b *Buffer) MatchIndex(s string, useRegex bool, start Loc) (int, int, error) {
+	if s == "" {
+		return 0, 0, nil
+	}
+
+	r, err := b.searchRegexp(s, useRegex)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	m := b.matches
+	if m == nil || m.regexp != r.String() {
+		m = &matchCache{regexp: r.String()}
+		for i := 0; i < b.LinesNum(); i++ {
+			l := b.LineBytes(i)
+			for _, match := range r.FindAllIndex(l, -1) {
+				m.locs = append(m.locs, Loc{util.RunePos(l, match[0]), i})
+			}
+		}
+		b.matches = m
+	}
+
+	i := sort.Search(len(m.locs), func(i int) bool {
+		return m.locs[i].GreaterEqual(start)
+	})
+	if i < len(m.locs) && m.locs[i] == start {
+		return i + 1, len(m.locs), nil
+	}
+	return 0, len(m.locs), nil
+}
+
 // ReplaceRegex replaces all occurrences of 'search' with 'replace' in the given area
 // and returns the number of replacements made and the number of runes
 // added or removed on the last line of the range
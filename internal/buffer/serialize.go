@@ -1,15 +1,11 @@
 package buffer
 
 import (
+	"bytes"
 	"encoding/gob"
-	"errors"
-	"io"
 	"os"
-	"path/filepath"
 	"time"
 
-	"golang.org/x/text/encoding"
-
 	"github.com/zyedidia/micro/v2/internal/config"
 	"github.com/zyedidia/micro/v2/internal/util"
 )
@@ -31,16 +27,20 @@ func (b *Buffer) Serialize() error {
 		return nil
 	}
 
-	name := filepath.Join(config.ConfigDir, "buffers", util.EscapePath(b.AbsPath))
+	// the state may have been cleared since the buffer was opened
+	os.MkdirAll(config.StatePath("buffers"), os.ModePerm)
+	name := config.StatePath("buffers", util.EscapePath(b.AbsPath))
 
-	return overwriteFile(name, encoding.Nop, func(file io.Writer) error {
-		err := gob.NewEncoder(file).Encode(SerializedBuffer{
-			b.EventHandler,
-			b.GetActiveCursor().Loc,
-			b.ModTime,
-		})
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(SerializedBuffer{
+		b.EventHandler,
+		b.GetActiveCursor().Loc,
+		b.ModTime,
+	})
+	if err != nil {
 		return err
-	}, false)
+	}
+	return config.WriteStateFile(name, buf.Bytes())
 }
 
 // Unserialize loads the buffer info from config.ConfigDir/buffers
@@ -50,14 +50,17 @@ func (b *Buffer) Unserialize() error {
 	if b.Path == "" {
 		return nil
 	}
-	file, err := os.Open(filepath.Join(config.ConfigDir, "buffers", util.EscapePath(b.AbsPath)))
+	name := config.StatePath("buffers", util.EscapePath(b.AbsPath))
+	file, err := os.Open(name)
 	if err == nil {
 		defer file.Close()
 		var buffer SerializedBuffer
 		decoder := gob.NewDecoder(file)
 		err = decoder.Decode(&buffer)
 		if err != nil {
-			return errors.New(err.Error() + "\nYou may want to remove the files in ~/.config/macro/buffers if\nthis problem persists.")
+			// the file is corrupt, start over without it
+			os.Remove(name)
+			return nil
 		}
 		if b.Settings["savecursor"].(bool) {
 			b.StartCursor = buffer.Cursor
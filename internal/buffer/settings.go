@@ -39,6 +39,7 @@ func (b *Buffer) SetOptionNative(option string, nativeValue interface{}) error {
 			b.Endings = FFDos
 		}
 		b.isModified = true
+		b.sizeValid = false
 	} else if option == "syntax" {
 		if !nativeValue.(bool) {
 			b.ClearMatches()
@@ -41,3 +41,22 @@ func (s *TEStack) Peek() *TextEvent {
 	}
 	return nil
 }
+
+// Trim removes the oldest elements of the stack so that it holds at most max
+// elements
+func (s *TEStack) Trim(max int) {
+	if s.Size <= max {
+		return
+	}
+	if max <= 0 {
+		s.Top = nil
+		s.Size = 0
+		return
+	}
+	e := s.Top
+	for i := 1; i < max; i++ {
+		e = e.Next
+	}
+	e.Next = nil
+	s.Size = max
+}
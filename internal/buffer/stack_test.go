@@ -33,3 +33,26 @@ func TestStack(t *testing.T) {
 	p = s.Peek()
 	assert.Nil(t, p)
 }
+
+func TestStackTrim(t *testing.T) {
+	s := new(TEStack)
+	for i := 0; i < 5; i++ {
+		s.Push(&TextEvent{Deltas: []Delta{{Start: Loc{i, 0}}}})
+	}
+
+	s.Trim(10)
+	assert.Equal(t, 5, s.Len())
+
+	// the oldest events are dropped
+	s.Trim(3)
+	assert.Equal(t, 3, s.Len())
+	assert.Equal(t, 4, s.Pop().Deltas[0].Start.X)
+	assert.Equal(t, 3, s.Pop().Deltas[0].Start.X)
+	assert.Equal(t, 2, s.Pop().Deltas[0].Start.X)
+	assert.Nil(t, s.Pop())
+
+	s.Push(&TextEvent{})
+	s.Trim(0)
+	assert.Equal(t, 0, s.Len())
+	assert.Nil(t, s.Peek())
+}
@@ -0,0 +1,138 @@
+package buffer
+
+import (
+	"regexp"
+)
+
+// A Symbol is a definition (function, type...) or a heading found in a
+// buffer
+type Symbol struct {
+	Name string
+	// Line is the line of the definition, starting at 0
+	Line int
+	// Depth is the nesting level of the symbol, starting at 0
+	Depth int
+}
+
+// symbolPatterns are, by filetype, the regular expressions matching the lines
+// defining a symbol. The first submatch is the name of the symbol
+var symbolPatterns = map[string][]*regexp.Regexp{
+	"go": {
+		regexp.MustCompile(`^func\s+(?:\([^)]*\)\s*)?(\w+)`),
+		regexp.MustCompile(`^type\s+(\w+)`),
+		regexp.MustCompile(`^(?:const|var)\s+(\w+)`),
+	},
+	"python": {
+		regexp.MustCompile(`^\s*(?:async\s+)?def\s+(\w+)`),
+		regexp.MustCompile(`^\s*class\s+(\w+)`),
+	},
+}
+
+// A symbolGroup matches a group of definitions, e.g. `const (` in Go
+type symbolGroup struct {
+	// open and close match the first and last lines of the group
+	open, close *regexp.Regexp
+	// member matches the lines of the group defining a symbol, whose name is
+	// the first submatch. Only the least indented ones are kept, the others
+	// being part of a definition
+	member *regexp.Regexp
+}
+
+// symbolGroups are, by filetype, the groups of definitions
+var symbolGroups = map[string]symbolGroup{
+	"go": {
+		open:   regexp.MustCompile(`^(?:type|const|var)\s*\(\s*$`),
+		close:  regexp.MustCompile(`^\)`),
+		member: regexp.MustCompile(`^\s+(\w+)`),
+	},
+}
+
+var (
+	headingPattern = regexp.MustCompile(`^(#{1,6})\s+(.*\S)`)
+	fencePattern   = regexp.MustCompile("^\\s*(```|~~~)")
+)
+
+// Symbols returns the definitions of the buffer, in order, according to its
+// filetype, or its headings for markdown. The depth of a definition is the
+// number of definitions with less indentation containing it, and the members
+// of a group of definitions are at the depth of the group. It returns nil if
+// the filetype is not supported
+func (b *Buffer) Symbols() []Symbol {
+	ft := b.Settings["filetype"].(string)
+	if ft == "markdown" {
+		return b.headings()
+	}
+	patterns := symbolPatterns[ft]
+	if patterns == nil {
+		return nil
+	}
+
+	group, hasGroups := symbolGroups[ft]
+
+	var symbols []Symbol
+	// indents of the enclosing symbols
+	var indents []int
+	leave := func(y int) int {
+		indent, _ := b.indentWidth(y)
+		for len(indents) > 0 && indents[len(indents)-1] >= indent {
+			indents = indents[:len(indents)-1]
+		}
+		return indent
+	}
+	// whether the line is in a group of definitions, and the indent of its
+	// members, -1 until known
+	inGroup, memberIndent := false, -1
+	for y := 0; y < b.LinesNum(); y++ {
+		l := b.LineBytes(y)
+		if inGroup {
+			if group.close.Match(l) {
+				inGroup = false
+			} else if m := group.member.FindSubmatch(l); m != nil {
+				indent, _ := b.indentWidth(y)
+				if memberIndent < 0 {
+					memberIndent = indent
+				}
+				if indent == memberIndent {
+					symbols = append(symbols, Symbol{Name: string(m[1]), Line: y, Depth: len(indents)})
+				}
+			}
+			continue
+		}
+		if hasGroups && group.open.Match(l) {
+			// the members are at the level of the group
+			leave(y)
+			inGroup, memberIndent = true, -1
+			continue
+		}
+		for _, re := range patterns {
+			if m := re.FindSubmatch(l); m != nil {
+				indent := leave(y)
+				symbols = append(symbols, Symbol{Name: string(m[1]), Line: y, Depth: len(indents)})
+				indents = append(indents, indent)
+				break
+			}
+		}
+	}
+	return symbols
+}
+
+// headings returns the ATX headings of a markdown buffer, outside of fenced
+// code blocks, with a depth following their level
+func (b *Buffer) headings() []Symbol {
+	var symbols []Symbol
+	fenced := false
+	for y := 0; y < b.LinesNum(); y++ {
+		l := b.LineBytes(y)
+		if fencePattern.Match(l) {
+			fenced = !fenced
+			continue
+		}
+		if fenced {
+			continue
+		}
+		if m := headingPattern.FindSubmatch(l); m != nil {
+			symbols = append(symbols, Symbol{Name: string(m[2]), Line: y, Depth: len(m[1]) - 1})
+		}
+	}
+	return symbols
+}
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+)
+
+// readBookmarks returns the bookmarked lines of every file, by absolute path
+func readBookmarks() map[string][]int {
+	marks := make(map[string][]int)
+	data, err := ioutil.ReadFile(StatePath("bookmarks.json"))
+	if err != nil {
+		return marks
+	}
+	if json.Unmarshal(data, &marks) != nil {
+		return make(map[string][]int)
+	}
+	return marks
+}
+
+// LoadBookmarks returns the bookmarked lines (starting at 0) of the file with
+// the given absolute path
+func LoadBookmarks(path string) []int {
+	return readBookmarks()[path]
+}
+
+// SaveBookmarks saves the bookmarked lines of the file with the given
+// absolute path. The file is forgotten if it has no bookmarks
+func SaveBookmarks(path string, lines []int) error {
+	marks := readBookmarks()
+	if len(lines) == 0 {
+		if _, ok := marks[path]; !ok {
+			return nil
+		}
+		delete(marks, path)
+	} else {
+		marks[path] = lines
+	}
+
+	data, err := json.MarshalIndent(marks, "", "    ")
+	if err != nil {
+		return err
+	}
+	return WriteStateFile(StatePath("bookmarks.json"), append(data, '\n'))
+}
@@ -0,0 +1,24 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBookmarks(t *testing.T) {
+	dir := ConfigDir
+	defer func() { ConfigDir = dir }()
+	ConfigDir = t.TempDir()
+
+	assert.Empty(t, LoadBookmarks("/tmp/a"))
+
+	assert.Nil(t, SaveBookmarks("/tmp/a", []int{1, 5}))
+	assert.Nil(t, SaveBookmarks("/tmp/b", []int{0}))
+	assert.Equal(t, []int{1, 5}, LoadBookmarks("/tmp/a"))
+	assert.Equal(t, []int{0}, LoadBookmarks("/tmp/b"))
+
+	assert.Nil(t, SaveBookmarks("/tmp/a", nil))
+	assert.Empty(t, LoadBookmarks("/tmp/a"))
+	assert.Equal(t, []int{0}, LoadBookmarks("/tmp/b"))
+}
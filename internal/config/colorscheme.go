@@ -55,6 +55,13 @@ func InitColorscheme() error {
 	c, err := LoadDefaultColorscheme()
 	if err == nil {
 		Colorscheme = c
+	} else {
+		// fall back to the default colorscheme rather than to no colors, for
+		// example if the configured colorscheme has been removed
+		var parsedColorschemes []string
+		if c, derr := LoadColorscheme(DefaultGlobalOnlySettings["colorscheme"].(string), &parsedColorschemes); derr == nil {
+			Colorscheme = c
+		}
 	}
 
 	return err
@@ -72,3 +72,17 @@ color-link constant.string.char "#BDE6AD,#282828"`
 	assert.Equal(t, tcell.NewRGBColor(117, 113, 94), fg)
 	assert.Equal(t, tcell.NewRGBColor(40, 40, 40), bg)
 }
+
+func TestInitColorschemeFallback(t *testing.T) {
+	settings := GlobalSettings
+	defer func() { GlobalSettings = settings }()
+	GlobalSettings = DefaultGlobalSettings()
+
+	assert.Nil(t, InitColorscheme())
+	def := Colorscheme
+	assert.NotEmpty(t, def)
+
+	GlobalSettings["colorscheme"] = "nonexistent"
+	assert.NotNil(t, InitColorscheme())
+	assert.Equal(t, def, Colorscheme)
+}
@@ -220,31 +220,12 @@ func (pp *PluginPackage) UnmarshalJSON(data []byte) error {
 // GetAllPluginPackages gets all PluginPackages which may be available.
 func GetAllPluginPackages(out io.Writer) PluginPackages {
 	if allPluginPackages == nil {
-		getOption := func(name string) []string {
-			data := GetGlobalOption(name)
-			if strs, ok := data.([]string); ok {
-				return strs
-			}
-			if ifs, ok := data.([]interface{}); ok {
-				result := make([]string, len(ifs))
-				for i, urlIf := range ifs {
-					if url, ok := urlIf.(string); ok {
-						result[i] = url
-					} else {
-						return nil
-					}
-				}
-				return result
-			}
-			return nil
-		}
-
 		channels := PluginChannels{}
-		for _, url := range getOption("pluginchannels") {
+		for _, url := range GetGlobalStrings("pluginchannels") {
 			channels = append(channels, PluginChannel(url))
 		}
 		repos := []PluginRepository{}
-		for _, url := range getOption("pluginrepos") {
+		for _, url := range GetGlobalStrings("pluginrepos") {
 			repos = append(repos, PluginRepository(url))
 		}
 		allPluginPackages = fetchAllSources(len(repos)+1, func(i int) PluginPackages {
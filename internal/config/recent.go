@@ -0,0 +1,40 @@
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+)
+
+// MaxRecentFiles is the number of files remembered in the recent files list
+const MaxRecentFiles = 50
+
+// RecentFiles returns the absolute paths of the files opened most recently,
+// across sessions, the most recent first
+func RecentFiles() []string {
+	var files []string
+	data, err := ioutil.ReadFile(StatePath("recent.json"))
+	if err != nil {
+		return nil
+	}
+	if json.Unmarshal(data, &files) != nil {
+		return nil
+	}
+	return files
+}
+
+// AddRecentFile moves the given absolute path to the front of the recent
+// files list, dropping the oldest files past MaxRecentFiles
+func AddRecentFile(path string) error {
+	files := []string{path}
+	for _, f := range RecentFiles() {
+		if f != path && len(files) < MaxRecentFiles {
+			files = append(files, f)
+		}
+	}
+
+	data, err := json.MarshalIndent(files, "", "    ")
+	if err != nil {
+		return err
+	}
+	return WriteStateFile(StatePath("recent.json"), append(data, '\n'))
+}
@@ -0,0 +1,29 @@
+package config
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecentFiles(t *testing.T) {
+	dir := ConfigDir
+	defer func() { ConfigDir = dir }()
+	ConfigDir = t.TempDir()
+
+	assert.Empty(t, RecentFiles())
+
+	assert.Nil(t, AddRecentFile("/tmp/a"))
+	assert.Nil(t, AddRecentFile("/tmp/b"))
+	assert.Nil(t, AddRecentFile("/tmp/a"))
+	assert.Equal(t, []string{"/tmp/a", "/tmp/b"}, RecentFiles())
+
+	for i := 0; i < MaxRecentFiles; i++ {
+		assert.Nil(t, AddRecentFile("/tmp/"+strconv.Itoa(i)))
+	}
+	files := RecentFiles()
+	assert.Len(t, files, MaxRecentFiles)
+	assert.Equal(t, "/tmp/"+strconv.Itoa(MaxRecentFiles-1), files[0])
+	assert.NotContains(t, files, "/tmp/a")
+}
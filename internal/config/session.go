@@ -0,0 +1,42 @@
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+)
+
+// A SessionBuffer is a file which was open in a saved editing session
+type SessionBuffer struct {
+	Path string `json:"path"`
+	// X and Y are the location of the cursor in the buffer
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// A Session is the list of files which were open when macro exited, so that
+// they can be opened again when macro is started without any file
+type Session struct {
+	Buffers []SessionBuffer `json:"buffers"`
+	// Current is the index of the buffer which was being edited
+	Current int `json:"current"`
+}
+
+// SaveSession saves the given session in the config directory
+func SaveSession(s Session) error {
+	data, err := json.MarshalIndent(s, "", "    ")
+	if err != nil {
+		return err
+	}
+	return WriteStateFile(StatePath("session.json"), append(data, '\n'))
+}
+
+// LoadSession reads the session saved in the config directory
+func LoadSession() (Session, error) {
+	var s Session
+	data, err := ioutil.ReadFile(StatePath("session.json"))
+	if err != nil {
+		return s, err
+	}
+	err = json.Unmarshal(data, &s)
+	return s, err
+}
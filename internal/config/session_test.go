@@ -0,0 +1,33 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSession(t *testing.T) {
+	dir := ConfigDir
+	defer func() { ConfigDir = dir }()
+	ConfigDir = t.TempDir()
+
+	_, err := LoadSession()
+	assert.NotNil(t, err)
+
+	s := Session{
+		Buffers: []SessionBuffer{
+			{Path: "/tmp/foo.go", X: 3, Y: 10},
+			{Path: "/tmp/bar.md"},
+		},
+		Current: 1,
+	}
+	assert.Nil(t, SaveSession(s))
+
+	loaded, err := LoadSession()
+	assert.Nil(t, err)
+	assert.Equal(t, s, loaded)
+
+	assert.Nil(t, ClearState())
+	_, err = LoadSession()
+	assert.NotNil(t, err)
+}
@@ -29,18 +29,21 @@ var optionValidators = map[string]optionValidator{
 	"detectlimit":     validateNonNegativeValue,
 	"encoding":        validateEncoding,
 	"fileformat":      validateChoice,
+	"finalnewline":    validateChoice,
 	"matchbracestyle": validateChoice,
 	"multiopen":       validateChoice,
 	"reload":          validateChoice,
 	"scrollmargin":    validateNonNegativeValue,
 	"scrollspeed":     validateNonNegativeValue,
 	"tabsize":         validatePositiveValue,
+	"undolimit":       validateNonNegativeValue,
 }
 
 // a list of settings with pre-defined choices
 var OptionChoices = map[string][]string{
 	"clipboard":       {"internal", "external", "terminal"},
 	"fileformat":      {"unix", "dos"},
+	"finalnewline":    {"ensure", "preserve", "trim"},
 	"matchbracestyle": {"underline", "highlight"},
 	"multiopen":       {"tab", "hsplit", "vsplit"},
 	"reload":          {"prompt", "auto", "disabled"},
@@ -63,38 +66,49 @@ var defaultCommonSettings = map[string]interface{}{
 	"fastdirty":       false,
 	"fileformat":      defaultFileFormat(),
 	"filetype":        "unknown",
+	"finalnewline":    "ensure",
+	"formatonsave":    false,
 	"hlsearch":        false,
 	"hltaberrors":     false,
 	"hltrailingws":    false,
 	"incsearch":       true,
 	"ignorecase":      true,
 	"indentchar":      " ",
+	"indentguide":     false,
 	"keepautoindent":  false,
+	"largefilesize":   float64(10000000),
 	"matchbrace":      true,
 	"matchbracestyle": "underline",
+	"minimap":         false,
 	"mkparents":       false,
 	"permbackup":      false,
+	"rainbowbrackets": false,
 	"readonly":        false,
 	"reload":          "prompt",
 	"rmtrailingws":    false,
 	"ruler":           true,
 	"relativeruler":   false,
+	"savebackup":      false,
 	"savecursor":      false,
 	"saveundo":        false,
 	"scrollbar":       false,
 	"scrollmargin":    float64(3),
 	"scrollspeed":     float64(2),
+	"smartindent":     true,
 	"smartpaste":      true,
 	"softwrap":        false,
 	"splitbottom":     true,
 	"splitright":      true,
-	"statusformatl":   "$(filename) $(modified)($(line),$(col)) $(status.paste)| ft:$(opt:filetype) | $(opt:fileformat) | $(opt:encoding)",
+	"statusformatl":   "$(filename) $(modified)$(recording)($(line),$(col)) $(status.paste)| ft:$(opt:filetype) | $(opt:fileformat) | $(opt:encoding)",
 	"statusformatr":   "$(bind:ToggleKeyMenu): bindings, $(bind:ToggleHelp): help",
 	"statusline":      true,
+	"stickyheader":    false,
 	"syntax":          true,
 	"tabmovement":     false,
 	"tabsize":         float64(4),
 	"tabstospaces":    false,
+	"timestampformat": "datetime",
+	"undolimit":       float64(10000),
 	"useprimary":      true,
 	"wordwrap":        false,
 }
@@ -102,26 +116,28 @@ var defaultCommonSettings = map[string]interface{}{
 // a list of settings that should only be globally modified and their
 // default values
 var DefaultGlobalOnlySettings = map[string]interface{}{
-	"autosave":       float64(0),
-	"clipboard":      "external",
-	"colorscheme":    "default",
-	"divchars":       "|-",
-	"divreverse":     true,
-	"fakecursor":     false,
-	"infobar":        true,
-	"keymenu":        false,
-	"mouse":          true,
-	"multiopen":      "tab",
-	"parsecursor":    false,
-	"paste":          false,
-	"pluginchannels": []string{"https://raw.githubusercontent.com/micro-editor/plugin-channel/master/channel.json"},
-	"pluginrepos":    []string{},
-	"savehistory":    true,
-	"scrollbarchar":  "|",
-	"sucmd":          "sudo",
-	"tabhighlight":   false,
-	"tabreverse":     true,
-	"xterm":          false,
+	"autosave":         float64(0),
+	"clipboard":        "external",
+	"colorscheme":      "default",
+	"disabledcommands": []string{},
+	"divchars":         "|-",
+	"divreverse":       true,
+	"fakecursor":       false,
+	"infobar":          true,
+	"keymenu":          false,
+	"mouse":            true,
+	"multiopen":        "tab",
+	"parsecursor":      false,
+	"paste":            false,
+	"pluginchannels":   []string{"https://raw.githubusercontent.com/micro-editor/plugin-channel/master/channel.json"},
+	"pluginrepos":      []string{},
+	"savehistory":      true,
+	"savesession":      true,
+	"scrollbarchar":    "|",
+	"sucmd":            "sudo",
+	"tabhighlight":     false,
+	"tabreverse":       true,
+	"xterm":            false,
 }
 
 // a list of settings that should never be globally modified
@@ -191,7 +207,7 @@ func ReadSettings() error {
 func verifySetting(option string, value reflect.Type, def reflect.Type) bool {
 	var interfaceArr []interface{}
 	switch option {
-	case "pluginrepos", "pluginchannels":
+	case "pluginrepos", "pluginchannels", "disabledcommands":
 		return value.AssignableTo(reflect.TypeOf(interfaceArr))
 	default:
 		return def.AssignableTo(value)
@@ -351,6 +367,27 @@ func GetGlobalOption(name string) interface{} {
 	return GlobalSettings[name]
 }
 
+// GetGlobalStrings returns the global value of the given list option
+// as a list of strings, or nil if it contains anything but strings
+func GetGlobalStrings(name string) []string {
+	data := GetGlobalOption(name)
+	if strs, ok := data.([]string); ok {
+		return strs
+	}
+	if ifs, ok := data.([]interface{}); ok {
+		result := make([]string, len(ifs))
+		for i, sIf := range ifs {
+			if s, ok := sIf.(string); ok {
+				result[i] = s
+			} else {
+				return nil
+			}
+		}
+		return result
+	}
+	return nil
+}
+
 func defaultFileFormat() string {
 	if runtime.GOOS == "windows" {
 		return "dos"
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// snippetsFile caches the content of snippets.json, which is only read again
+// when it changes
+var snippetsFile struct {
+	path    string
+	modTime time.Time
+	size    int64
+	all     map[string]map[string]string
+}
+
+// readSnippets returns the snippets of every filetype from snippets.json
+func readSnippets() (map[string]map[string]string, error) {
+	path := filepath.Join(ConfigDir, "snippets.json")
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	f := &snippetsFile
+	if f.path == path && f.modTime.Equal(info.ModTime()) && f.size == info.Size() {
+		return f.all, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var all map[string]map[string]string
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, errors.New("Error reading snippets.json: " + err.Error())
+	}
+	f.path, f.modTime, f.size, f.all = path, info.ModTime(), info.Size(), all
+	return all, nil
+}
+
+// Snippets returns the snippets defined for the given filetype in
+// snippets.json, by name. The snippets of the "*" entry are available for
+// every filetype, unless the filetype has one with the same name
+func Snippets(filetype string) (map[string]string, error) {
+	all, err := readSnippets()
+	if err != nil {
+		return nil, err
+	}
+
+	snippets := make(map[string]string)
+	for name, body := range all["*"] {
+		snippets[name] = body
+	}
+	for name, body := range all[filetype] {
+		snippets[name] = body
+	}
+	return snippets, nil
+}
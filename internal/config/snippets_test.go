@@ -0,0 +1,31 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnippets(t *testing.T) {
+	dir := ConfigDir
+	defer func() { ConfigDir = dir }()
+	ConfigDir = t.TempDir()
+
+	s, err := Snippets("go")
+	assert.Nil(t, err)
+	assert.Empty(t, s)
+
+	data := `{"*": {"todo": "TODO: $0", "if": "if"}, "go": {"if": "if $1 {\n\t$0\n}"}}`
+	assert.Nil(t, os.WriteFile(filepath.Join(ConfigDir, "snippets.json"), []byte(data), 0644))
+	s, err = Snippets("go")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"todo": "TODO: $0", "if": "if $1 {\n\t$0\n}"}, s)
+	s, _ = Snippets("python")
+	assert.Equal(t, map[string]string{"todo": "TODO: $0", "if": "if"}, s)
+
+	assert.Nil(t, os.WriteFile(filepath.Join(ConfigDir, "snippets.json"), []byte("{"), 0644))
+	_, err = Snippets("go")
+	assert.NotNil(t, err)
+}
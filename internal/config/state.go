@@ -0,0 +1,85 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// StateFiles lists, relative to the config directory, the files and
+// directories in which macro persists state between sessions (cursor
+// positions, undo and command history, open and recent files, view toggles
+// and bookmarks)
+var StateFiles = []string{"buffers", "session.json", "recent.json", "view.json", "bookmarks.json"}
+
+// A StateEntry is an existing file or directory holding persisted state
+type StateEntry struct {
+	Path string
+	// Size is the total size in bytes of the file or directory
+	Size int64
+}
+
+// StatePath returns the path of a file or directory holding persisted state
+func StatePath(elem ...string) string {
+	return filepath.Join(append([]string{ConfigDir}, elem...)...)
+}
+
+// WriteStateFile writes data to the given state file atomically: the data is
+// written to a temporary file in the same directory, which then replaces the
+// file, so that an interrupted write never leaves a truncated file
+func WriteStateFile(path string, data []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if err2 := f.Close(); err == nil {
+		err = err2
+	}
+	if err == nil {
+		err = os.Chmod(tmp, 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+// ShowState returns the state files and directories which currently exist
+func ShowState() []StateEntry {
+	var entries []StateEntry
+	for _, f := range StateFiles {
+		p := StatePath(f)
+		if _, err := os.Stat(p); err != nil {
+			continue
+		}
+
+		var size int64
+		filepath.Walk(p, func(_ string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() {
+				size += info.Size()
+			}
+			return nil
+		})
+		entries = append(entries, StateEntry{p, size})
+	}
+	return entries
+}
+
+// ClearState removes all the persisted state
+func ClearState() error {
+	for _, f := range StateFiles {
+		if err := os.RemoveAll(StatePath(f)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
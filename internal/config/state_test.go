@@ -0,0 +1,49 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestState(t *testing.T) {
+	dir := ConfigDir
+	defer func() { ConfigDir = dir }()
+	ConfigDir = t.TempDir()
+
+	assert.Empty(t, ShowState())
+
+	os.Mkdir(StatePath("buffers"), os.ModePerm)
+	os.WriteFile(StatePath("buffers", "history"), []byte("history"), 0644)
+	os.WriteFile(StatePath("buffers", "foo"), []byte("cursor"), 0644)
+	os.WriteFile(filepath.Join(ConfigDir, "settings.json"), []byte("{}"), 0644)
+
+	entries := ShowState()
+	assert.Equal(t, []StateEntry{{StatePath("buffers"), 13}}, entries)
+
+	assert.Nil(t, ClearState())
+	assert.Empty(t, ShowState())
+	_, err := os.Stat(StatePath("buffers"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(ConfigDir, "settings.json"))
+	assert.Nil(t, err)
+}
+
+func TestWriteStateFile(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "state.json")
+
+	assert.Nil(t, WriteStateFile(p, []byte("first")))
+	assert.Nil(t, WriteStateFile(p, []byte("second")))
+	data, err := os.ReadFile(p)
+	assert.Nil(t, err)
+	assert.Equal(t, "second", string(data))
+
+	// no temporary file is left behind
+	entries, _ := os.ReadDir(dir)
+	assert.Len(t, entries, 1)
+
+	assert.NotNil(t, WriteStateFile(filepath.Join(dir, "missing", "state.json"), nil))
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+)
+
+// ViewOptions are the options changing the layout of the editor which have a
+// toggle action, and whose toggled value is remembered between sessions
+var ViewOptions = []string{"diffgutter", "indentguide", "keymenu", "relativeruler", "ruler", "stickyheader"}
+
+// ViewPrefs holds the values of the view options which were last toggled.
+// Setting an option with the set command takes precedence over a toggle
+var ViewPrefs = make(map[string]bool)
+
+// RememberView records the value a view option was toggled to
+func RememberView(option string, value bool) {
+	for _, o := range ViewOptions {
+		if o == option {
+			ViewPrefs[option] = value
+			return
+		}
+	}
+}
+
+// ForgetView drops the toggled value of a view option
+func ForgetView(option string) {
+	delete(ViewPrefs, option)
+}
+
+// SaveViewPrefs saves the toggled view options in the config directory
+func SaveViewPrefs() error {
+	if len(ViewPrefs) == 0 {
+		err := os.Remove(StatePath("view.json"))
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	data, err := json.MarshalIndent(ViewPrefs, "", "    ")
+	if err != nil {
+		return err
+	}
+	return WriteStateFile(StatePath("view.json"), append(data, '\n'))
+}
+
+// LoadViewPrefs reads the toggled view options saved in the config directory
+// and applies them to the global settings. Must be called after
+// InitGlobalSettings, and before command line options are applied so that
+// they can override the saved values
+func LoadViewPrefs() error {
+	ViewPrefs = make(map[string]bool)
+	data, err := ioutil.ReadFile(StatePath("view.json"))
+	if err != nil {
+		return err
+	}
+
+	var prefs map[string]bool
+	if err := json.Unmarshal(data, &prefs); err != nil {
+		return err
+	}
+	for _, o := range ViewOptions {
+		if v, ok := prefs[o]; ok {
+			ViewPrefs[o] = v
+			GlobalSettings[o] = v
+			// like command line options, the restored values are not
+			// written to settings.json, nor remove its values when they
+			// match the defaults
+			VolatileSettings[o] = true
+		}
+	}
+	return nil
+}
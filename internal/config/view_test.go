@@ -0,0 +1,47 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestViewPrefs(t *testing.T) {
+	dir := ConfigDir
+	defer func() { ConfigDir = dir }()
+	ConfigDir = t.TempDir()
+	settings := GlobalSettings
+	defer func() { GlobalSettings = settings }()
+
+	volatile := VolatileSettings
+	defer func() { VolatileSettings = volatile }()
+
+	GlobalSettings = DefaultGlobalSettings()
+	VolatileSettings = make(map[string]bool)
+	assert.NotNil(t, LoadViewPrefs())
+	assert.Equal(t, true, GlobalSettings["ruler"])
+
+	RememberView("ruler", false)
+	RememberView("stickyheader", true)
+	RememberView("tabsize", true)
+	assert.Nil(t, SaveViewPrefs())
+
+	GlobalSettings = DefaultGlobalSettings()
+	assert.Nil(t, LoadViewPrefs())
+	assert.Equal(t, map[string]bool{"ruler": false, "stickyheader": true}, ViewPrefs)
+	assert.Equal(t, false, GlobalSettings["ruler"])
+	assert.Equal(t, true, GlobalSettings["stickyheader"])
+	assert.Equal(t, false, GlobalSettings["indentguide"])
+	assert.True(t, VolatileSettings["ruler"])
+	assert.False(t, VolatileSettings["indentguide"])
+
+	ForgetView("ruler")
+	assert.Nil(t, SaveViewPrefs())
+	GlobalSettings = DefaultGlobalSettings()
+	assert.Nil(t, LoadViewPrefs())
+	assert.Equal(t, true, GlobalSettings["ruler"])
+
+	ForgetView("stickyheader")
+	assert.Nil(t, SaveViewPrefs())
+	assert.Empty(t, ShowState())
+}
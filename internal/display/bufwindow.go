@@ -28,6 +28,12 @@ type BufWindow struct {
 	hasMessage       bool
 	maxLineNumLength int
 	drawDivider      bool
+	minimapWidth     int
+
+	// headerLine is the context line pinned at the top of the window
+	// when the stickyheader option is on, or -1
+	headerLine   int
+	headerHeight int
 }
 
 // NewBufWindow creates a new window at a location in the screen with a width and height
@@ -101,7 +107,7 @@ func (w *BufWindow) IsActive() bool {
 func (w *BufWindow) BufView() View {
 	return View{
 		X:         w.X + w.gutterOffset,
-		Y:         w.Y,
+		Y:         w.Y + w.headerHeight,
 		Width:     w.bufWidth,
 		Height:    w.bufHeight,
 		StartLine: w.StartLine,
@@ -129,12 +135,21 @@ func (w *BufWindow) updateDisplayInfo() {
 		w.bufHeight--
 	}
 
+	w.headerLine, w.headerHeight = -1, 0
+	if b.Settings["stickyheader"].(bool) && w.bufHeight > 1 {
+		w.headerLine = b.ContextLine(w.StartLine.Line)
+		if w.headerLine >= 0 {
+			w.headerHeight = 1
+			w.bufHeight--
+		}
+	}
+
 	scrollbarWidth := 0
 	if w.Buf.Settings["scrollbar"].(bool) && w.Buf.LinesNum() > w.Height && w.Width > 0 {
 		scrollbarWidth = 1
 	}
 
-	w.hasMessage = len(b.Messages) > 0
+	w.hasMessage = len(b.Messages) > 0 || len(b.Bookmarks()) > 0
 
 	// We need to know the string length of the largest line number
 	// so we can pad appropriately when displaying line numbers
@@ -155,8 +170,13 @@ func (w *BufWindow) updateDisplayInfo() {
 		w.gutterOffset = w.Width - scrollbarWidth
 	}
 
+	w.minimapWidth = 0
+	if b.Settings["minimap"].(bool) && w.Width-w.gutterOffset-scrollbarWidth-minimapWidth >= minimapMinTextWidth {
+		w.minimapWidth = minimapWidth
+	}
+
 	prevBufWidth := w.bufWidth
-	w.bufWidth = w.Width - w.gutterOffset - scrollbarWidth
+	w.bufWidth = w.Width - w.gutterOffset - scrollbarWidth - w.minimapWidth
 
 	if w.bufWidth != prevBufWidth && w.Buf.Settings["softwrap"].(bool) {
 		for _, c := range w.Buf.GetCursors() {
@@ -266,24 +286,30 @@ func (w *BufWindow) LocFromVisual(svloc buffer.Loc) buffer.Loc {
 		vx = 0
 	}
 	vloc := VLoc{
-		SLoc:    w.Scroll(w.StartLine, svloc.Y-w.Y),
+		SLoc:    w.Scroll(w.StartLine, svloc.Y-w.Y-w.headerHeight),
 		VisualX: vx + w.StartCol,
 	}
 	return w.LocFromVLoc(vloc)
 }
 
 func (w *BufWindow) drawGutter(vloc *buffer.Loc, bloc *buffer.Loc) {
-	char := ' '
+	chars := [2]rune{' ', ' '}
 	s := config.DefStyle
 	for _, m := range w.Buf.Messages {
 		if m.Start.Y == bloc.Y || m.End.Y == bloc.Y {
 			s = m.Style()
-			char = '>'
+			chars = [2]rune{m.Glyph(), '>'}
 			break
 		}
 	}
+	if chars[0] == ' ' && w.Buf.Bookmarked(bloc.Y) {
+		chars = [2]rune{'*', '*'}
+		if style, ok := config.Colorscheme["gutter-info"]; ok {
+			s = style
+		}
+	}
 	for i := 0; i < 2 && vloc.X < w.gutterOffset; i++ {
-		screen.SetContent(w.X+vloc.X, w.Y+vloc.Y, char, nil, s)
+		screen.SetContent(w.X+vloc.X, w.Y+w.headerHeight+vloc.Y, chars[i], nil, s)
 		vloc.X++
 	}
 }
@@ -316,7 +342,7 @@ func (w *BufWindow) drawDiffGutter(backgroundStyle tcell.Style, softwrapped bool
 		style = style.Foreground(foreground)
 	}
 
-	screen.SetContent(w.X+vloc.X, w.Y+vloc.Y, symbol, nil, style)
+	screen.SetContent(w.X+vloc.X, w.Y+w.headerHeight+vloc.Y, symbol, nil, style)
 	vloc.X++
 }
 
@@ -332,22 +358,27 @@ func (w *BufWindow) drawLineNum(lineNumStyle tcell.Style, softwrapped bool, vloc
 
 	// Write the spaces before the line number if necessary
 	for i := 0; i < w.maxLineNumLength-len(lineNum) && vloc.X < w.gutterOffset; i++ {
-		screen.SetContent(w.X+vloc.X, w.Y+vloc.Y, ' ', nil, lineNumStyle)
+		screen.SetContent(w.X+vloc.X, w.Y+w.headerHeight+vloc.Y, ' ', nil, lineNumStyle)
 		vloc.X++
 	}
 	// Write the actual line number
 	for i := 0; i < len(lineNum) && vloc.X < w.gutterOffset; i++ {
 		if softwrapped || (w.bufWidth == 0 && w.Buf.Settings["softwrap"] == true) {
-			screen.SetContent(w.X+vloc.X, w.Y+vloc.Y, ' ', nil, lineNumStyle)
+			r := ' '
+			// the rows continuing a wrapped line are marked under its number
+			if softwrapped && i == len(lineNum)-1 {
+				r = '↪'
+			}
+			screen.SetContent(w.X+vloc.X, w.Y+w.headerHeight+vloc.Y, r, nil, lineNumStyle)
 		} else {
-			screen.SetContent(w.X+vloc.X, w.Y+vloc.Y, lineNum[i], nil, lineNumStyle)
+			screen.SetContent(w.X+vloc.X, w.Y+w.headerHeight+vloc.Y, lineNum[i], nil, lineNumStyle)
 		}
 		vloc.X++
 	}
 
 	// Write the extra space
 	if vloc.X < w.gutterOffset {
-		screen.SetContent(w.X+vloc.X, w.Y+vloc.Y, ' ', nil, lineNumStyle)
+		screen.SetContent(w.X+vloc.X, w.Y+w.headerHeight+vloc.Y, ' ', nil, lineNumStyle)
 		vloc.X++
 	}
 }
@@ -448,6 +479,10 @@ func (w *BufWindow) displayBuffer() {
 
 	tabsize := util.IntOpt(b.Settings["tabsize"])
 	colorcolumn := util.IntOpt(b.Settings["colorcolumn"])
+	var rainbow []tcell.Color
+	if b.Settings["rainbowbrackets"].(bool) {
+		rainbow = rainbowColors()
+	}
 
 	// this represents the current draw position
 	// within the current window
@@ -498,7 +533,39 @@ func (w *BufWindow) displayBuffer() {
 		bline := b.LineBytes(bloc.Y)
 		blineLen := util.CharacterCount(bline)
 
+		var bracketDepths map[int]int
+		if rainbow != nil {
+			bracketDepths = b.BracketDepths(bloc.Y)
+		}
+
 		leadingwsEnd := len(util.GetLeadingWhitespace(bline))
+		blank := leadingwsEnd == len(bline)
+		var guides []int
+		if b.Settings["indentguide"].(bool) {
+			if blank {
+				guides = w.blankLineGuides(bloc.Y, tabsize)
+			} else {
+				guides = util.IndentGuides(bline, tabsize)
+			}
+		}
+		isGuide := func(col int) bool {
+			for _, g := range guides {
+				if col == g {
+					return true
+				}
+			}
+			return false
+		}
+		guideStyle := func(style tcell.Style) tcell.Style {
+			if s, ok := config.Colorscheme["indent-guide"]; ok {
+				fg, _, _ := s.Decompose()
+				return style.Foreground(fg)
+			} else if s, ok := config.Colorscheme["indent-char"]; ok {
+				fg, _, _ := s.Decompose()
+				return style.Foreground(fg)
+			}
+			return style
+		}
 		trailingwsStart := blineLen - util.CharacterCount(util.GetTrailingWhitespace(bline))
 
 		line, nColsBeforeStart, bslice, startStyle := w.getStartInfo(w.StartCol, bloc.Y)
@@ -598,6 +665,12 @@ func (w *BufWindow) displayBuffer() {
 						}
 					}
 
+					// the guides of a blank line also go past its end
+					if r == ' ' && (bloc.X < leadingwsEnd || blank) && isGuide(vloc.X-w.gutterOffset+w.StartCol) {
+						r = '│'
+						style = guideStyle(style)
+					}
+
 					if s, ok := config.Colorscheme["color-column"]; ok {
 						if colorcolumn != 0 && vloc.X-w.gutterOffset+w.StartCol == colorcolumn && !dontOverrideBackground {
 							fg, _, _ := s.Decompose()
@@ -620,12 +693,12 @@ func (w *BufWindow) displayBuffer() {
 					}
 				}
 
-				screen.SetContent(w.X+vloc.X, w.Y+vloc.Y, r, combc, style)
+				screen.SetContent(w.X+vloc.X, w.Y+w.headerHeight+vloc.Y, r, combc, style)
 
 				if showcursor {
 					for _, c := range cursors {
 						if c.X == bloc.X && c.Y == bloc.Y && !c.HasSelection() {
-							w.showCursor(w.X+vloc.X, w.Y+vloc.Y, c.Num == 0)
+							w.showCursor(w.X+vloc.X, w.Y+w.headerHeight+vloc.Y, c.Num == 0)
 						}
 					}
 				}
@@ -673,6 +746,10 @@ func (w *BufWindow) displayBuffer() {
 
 			loc := buffer.Loc{X: bloc.X + len(word), Y: bloc.Y}
 			curStyle, _ = w.getStyle(curStyle, loc)
+			style := curStyle
+			if d, ok := bracketDepths[loc.X]; ok && d >= 0 {
+				style = style.Foreground(rainbow[d%len(rainbow)])
+			}
 
 			width := 0
 
@@ -686,7 +763,7 @@ func (w *BufWindow) displayBuffer() {
 				totalwidth += width
 			}
 
-			word = append(word, glyph{r, combc, curStyle, width})
+			word = append(word, glyph{r, combc, style, width})
 			wordwidth += width
 
 			// Collect a complete word to know its width.
@@ -767,7 +844,12 @@ func (w *BufWindow) displayBuffer() {
 					curStyle = style.Background(fg)
 				}
 			}
-			screen.SetContent(i+w.X, vloc.Y+w.Y, ' ', nil, curStyle)
+			r := ' '
+			if blank && isGuide(i-w.gutterOffset+w.StartCol) {
+				r = '│'
+				curStyle = guideStyle(curStyle)
+			}
+			screen.SetContent(i+w.X, vloc.Y+w.Y+w.headerHeight, r, nil, curStyle)
 		}
 
 		if vloc.X != maxWidth {
@@ -783,6 +865,104 @@ func (w *BufWindow) displayBuffer() {
 	}
 }
 
+// rainbowColors returns the colors of the brackets by nesting depth, for the
+// rainbowbrackets option: the rainbow-bracket-1, rainbow-bracket-2... colors
+// of the colorscheme, or a default palette if it doesn't define them
+func rainbowColors() []tcell.Color {
+	var colors []tcell.Color
+	for i := 1; ; i++ {
+		s, ok := config.Colorscheme["rainbow-bracket-"+strconv.Itoa(i)]
+		if !ok {
+			break
+		}
+		fg, _, _ := s.Decompose()
+		colors = append(colors, fg)
+	}
+	if len(colors) == 0 {
+		colors = []tcell.Color{tcell.ColorGold, tcell.ColorOrchid, tcell.ColorDeepSkyBlue}
+	}
+	return colors
+}
+
+// blankLineGuides returns the indent guides of the blank line y, from the
+// closest non-blank lines around it
+func (w *BufWindow) blankLineGuides(y, tabsize int) []int {
+	// don't look too far for big blocks of blank lines
+	const maxDist = 100
+
+	nonBlank := func(y, dir int) []byte {
+		for i := 0; i < maxDist; i++ {
+			y += dir
+			if y < 0 || y >= w.Buf.LinesNum() {
+				return nil
+			}
+			l := w.Buf.LineBytes(y)
+			if len(util.GetLeadingWhitespace(l)) != len(l) {
+				return l
+			}
+		}
+		return nil
+	}
+	return util.BlankLineGuides(nonBlank(y, -1), nonBlank(y, 1), tabsize)
+}
+
+// displayHeader draws the sticky header, the line of the enclosing block
+// of the first visible line, above the buffer content
+func (w *BufWindow) displayHeader() {
+	if w.headerHeight == 0 {
+		return
+	}
+
+	style := config.DefStyle.Underline(true)
+	if s, ok := config.Colorscheme["sticky-header"]; ok {
+		style = s
+	}
+	lineNumStyle := config.DefStyle
+	if s, ok := config.Colorscheme["line-number"]; ok {
+		lineNumStyle = s
+	}
+
+	// the header is drawn just above the first row of the buffer content
+	vloc := buffer.Loc{X: 0, Y: -1}
+	bloc := buffer.Loc{X: 0, Y: w.headerLine}
+	if w.hasMessage {
+		w.drawGutter(&vloc, &bloc)
+	}
+	if w.Buf.Settings["diffgutter"].(bool) {
+		w.drawDiffGutter(lineNumStyle, false, &vloc, &bloc)
+	}
+	if w.Buf.Settings["ruler"].(bool) {
+		w.drawLineNum(lineNumStyle, false, &vloc, &bloc)
+	}
+
+	tabsize := util.IntOpt(w.Buf.Settings["tabsize"])
+	maxWidth := w.gutterOffset + w.bufWidth
+	line := w.Buf.LineBytes(w.headerLine)
+	width := 0
+	for len(line) > 0 && vloc.X < maxWidth {
+		r, combc, size := util.DecodeCharacter(line)
+		line = line[size:]
+
+		cw := runewidth.RuneWidth(r)
+		if r == '\t' {
+			cw = tabsize - (width % tabsize)
+			r = ' '
+		}
+		width += cw
+		if vloc.X+cw > maxWidth {
+			break
+		}
+		screen.SetContent(w.X+vloc.X, w.Y, r, combc, style)
+		for i := 1; i < cw; i++ {
+			screen.SetContent(w.X+vloc.X+i, w.Y, ' ', nil, style)
+		}
+		vloc.X += cw
+	}
+	for ; vloc.X < maxWidth; vloc.X++ {
+		screen.SetContent(w.X+vloc.X, w.Y, ' ', nil, style)
+	}
+}
+
 func (w *BufWindow) displayStatusLine() {
 	if w.Buf.Settings["statusline"].(bool) {
 		w.sline.Display()
@@ -814,11 +994,13 @@ func (w *BufWindow) displayStatusLine() {
 func (w *BufWindow) displayScrollBar() {
 	if w.Buf.Settings["scrollbar"].(bool) && w.Buf.LinesNum() > w.Height {
 		scrollX := w.X + w.Width - 1
-		barsize := int(float64(w.Height) / float64(w.Buf.LinesNum()) * float64(w.Height))
+		// the bar spans the lines of the buffer, below the sticky header
+		y0 := w.Y + w.headerHeight
+		barsize := int(float64(w.bufHeight) / float64(w.Buf.LinesNum()) * float64(w.bufHeight))
 		if barsize < 1 {
 			barsize = 1
 		}
-		barstart := w.Y + int(float64(w.StartLine.Line)/float64(w.Buf.LinesNum())*float64(w.Height))
+		barstart := y0 + int(float64(w.StartLine.Line)/float64(w.Buf.LinesNum())*float64(w.bufHeight))
 
 		scrollBarStyle := config.DefStyle.Reverse(true)
 		if style, ok := config.Colorscheme["scrollbar"]; ok {
@@ -831,17 +1013,124 @@ func (w *BufWindow) displayScrollBar() {
 		}
 		scrollBarRune := []rune(scrollBarChar)
 
-		for y := barstart; y < util.Min(barstart+barsize, w.Y+w.bufHeight); y++ {
+		for y := barstart; y < util.Min(barstart+barsize, y0+w.bufHeight); y++ {
 			screen.SetContent(scrollX, y, scrollBarRune[0], nil, scrollBarStyle)
 		}
 	}
 }
 
+const (
+	// minimapWidth is the number of columns of the minimap
+	minimapWidth = 12
+	// minimapScale is the number of text columns of a minimap column
+	minimapScale = 8
+	// minimapMinTextWidth is the width left to the text under which the
+	// minimap is hidden
+	minimapMinTextWidth = 60
+)
+
+// displayMinimap draws an overview of the buffer on the right of the text.
+// Each row stands for the same number of lines, only the first of which is
+// sampled, and each column for minimapScale columns of text, drawn with the
+// color of their first non-blank character
+func (w *BufWindow) displayMinimap() {
+	if w.minimapWidth == 0 || w.bufHeight <= 0 {
+		return
+	}
+	b := w.Buf
+
+	x0 := w.X + w.gutterOffset + w.bufWidth
+	y0 := w.Y + w.headerHeight
+	step := (b.LinesNum() + w.bufHeight - 1) / w.bufHeight
+	if step < 1 {
+		step = 1
+	}
+	tabsize := util.IntOpt(b.Settings["tabsize"])
+
+	viewStyle := config.DefStyle.Reverse(true)
+	if style, ok := config.Colorscheme["cursor-line"]; ok {
+		_, bg, _ := style.Decompose()
+		viewStyle = config.DefStyle.Background(bg)
+	}
+	diffStyles := map[buffer.DiffStatus]string{
+		buffer.DSAdded:        "diff-added",
+		buffer.DSModified:     "diff-modified",
+		buffer.DSDeletedAbove: "diff-deleted",
+	}
+
+	for row := 0; row < w.bufHeight; row++ {
+		first := row * step
+		last := util.Min(first+step, b.LinesNum())
+
+		bg := config.DefStyle
+		if first < b.LinesNum() && last > w.StartLine.Line && first < w.StartLine.Line+w.bufHeight {
+			bg = viewStyle
+		}
+
+		// the changed lines take the color of the diff gutter
+		var diffStyle *tcell.Style
+		for y := first; y < last && diffStyle == nil; y++ {
+			if name, ok := diffStyles[b.DiffStatus(y)]; ok {
+				if style, ok := config.Colorscheme[name]; ok {
+					fg, _, _ := style.Decompose()
+					s := bg.Foreground(fg)
+					diffStyle = &s
+				}
+			}
+		}
+
+		cells := make([]*tcell.Style, w.minimapWidth)
+		if first < b.LinesNum() {
+			line := b.LineBytes(first)
+			matches := b.Match(first)
+			style := config.DefStyle
+			vx := 0
+			for i, r := range []rune(string(line)) {
+				if group, ok := matches[i]; ok {
+					style = config.GetColor(group.String())
+				}
+				col := vx / minimapScale
+				if col >= w.minimapWidth {
+					break
+				}
+				if r == '\t' {
+					vx += tabsize - vx%tabsize
+					continue
+				}
+				vx++
+				if cells[col] == nil && r != ' ' {
+					fg, _, _ := style.Decompose()
+					s := bg.Foreground(fg)
+					if diffStyle != nil {
+						s = *diffStyle
+					}
+					cells[col] = &s
+				}
+			}
+		}
+
+		for col, s := range cells {
+			if s != nil {
+				screen.SetContent(x0+col, y0+row, '▄', nil, *s)
+			} else {
+				screen.SetContent(x0+col, y0+row, ' ', nil, bg)
+			}
+		}
+	}
+}
+
 // Display displays the buffer and the statusline
 func (w *BufWindow) Display() {
+	headerHeight := w.headerHeight
 	w.updateDisplayInfo()
+	if w.headerHeight != headerHeight {
+		// the buffer content has lost or gained a row
+		w.Relocate()
+	}
 
 	w.displayStatusLine()
 	w.displayScrollBar()
+	w.displayHeader()
 	w.displayBuffer()
+	w.displayMinimap()
 }
@@ -9,6 +9,7 @@ import (
 
 	luar "layeh.com/gopher-luar"
 
+	humanize "github.com/dustin/go-humanize"
 	runewidth "github.com/mattn/go-runewidth"
 	lua "github.com/yuin/gopher-lua"
 	"github.com/zyedidia/micro/v2/internal/buffer"
@@ -53,6 +54,15 @@ var statusInfo = map[string]func(*buffer.Buffer) string{
 	"percentage": func(b *buffer.Buffer) string {
 		return strconv.Itoa((b.GetActiveCursor().Y + 1) * 100 / b.LinesNum())
 	},
+	"size": func(b *buffer.Buffer) string {
+		return humanize.Bytes(uint64(b.Size()))
+	},
+}
+
+// SetStatusInfoFn registers a function which can be used in the statusline
+// format options as $(name)
+func SetStatusInfoFn(name string, fn func(b *buffer.Buffer) string) {
+	statusInfo[name] = fn
 }
 
 func SetStatusInfoFnLua(fn string) {
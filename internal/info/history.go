@@ -1,9 +1,9 @@
 package info
 
 import (
+	"bytes"
 	"encoding/gob"
 	"os"
-	"path/filepath"
 	"strings"
 
 	"github.com/zyedidia/micro/v2/internal/config"
@@ -15,7 +15,7 @@ import (
 // The savehistory option must be on
 func (i *InfoBuf) LoadHistory() {
 	if config.GetGlobalOption("savehistory").(bool) {
-		file, err := os.Open(filepath.Join(config.ConfigDir, "buffers", "history"))
+		file, err := os.Open(config.StatePath("buffers", "history"))
 		var decodedMap map[string][]string
 		if err == nil {
 			defer file.Close()
@@ -23,8 +23,8 @@ func (i *InfoBuf) LoadHistory() {
 			err = decoder.Decode(&decodedMap)
 
 			if err != nil {
-				i.Error("Error loading history:", err)
-				return
+				// the history is corrupt, start over without it
+				decodedMap = nil
 			}
 		}
 
@@ -49,16 +49,15 @@ func (i *InfoBuf) SaveHistory() {
 			}
 		}
 
-		file, err := os.Create(filepath.Join(config.ConfigDir, "buffers", "history"))
+		os.MkdirAll(config.StatePath("buffers"), os.ModePerm)
+		var buf bytes.Buffer
+		err := gob.NewEncoder(&buf).Encode(i.History)
 		if err == nil {
-			defer file.Close()
-			encoder := gob.NewEncoder(file)
-
-			err = encoder.Encode(i.History)
-			if err != nil {
-				i.Error("Error saving history:", err)
-				return
-			}
+			err = config.WriteStateFile(config.StatePath("buffers", "history"), buf.Bytes())
+		}
+		if err != nil {
+			i.Error("Error saving history:", err)
+			return
 		}
 	}
 }
@@ -15,11 +15,19 @@ type InfoBuf struct {
 	HasMessage bool
 	HasError   bool
 	HasYN      bool
+	// HasYNAll indicates whether the yes or no prompt also accepts 'a'
+	// to answer yes to all the remaining questions
+	HasYNAll bool
+	// HasYNSave indicates whether the yes or no prompt also accepts 's'
+	// to save before answering yes
+	HasYNSave bool
 
 	PromptType string
 
 	Msg    string
 	YNResp bool
+	YNAll  bool
+	YNSave bool
 
 	// This map stores the history for all the different kinds of uses Prompt has
 	// It's a map of history type -> history array
@@ -128,11 +136,33 @@ func (i *InfoBuf) YNPrompt(prompt string, donecb func(bool, bool)) {
 	i.Msg = prompt
 	i.HasPrompt = true
 	i.HasYN = true
+	i.HasYNAll, i.YNAll = false, false
+	i.HasYNSave, i.YNSave = false, false
 	i.HasMessage, i.HasError = false, false
 	i.HasGutter = false
 	i.YNCallback = donecb
 }
 
+// YNAllPrompt creates a yes or no prompt which also accepts 'a' to answer yes
+// to all the remaining questions. The callback returns the yes/no result, whether
+// all was chosen and whether the prompt was canceled
+func (i *InfoBuf) YNAllPrompt(prompt string, donecb func(bool, bool, bool)) {
+	i.YNPrompt(prompt, func(yes, canceled bool) {
+		donecb(yes, i.YNAll, canceled)
+	})
+	i.HasYNAll = true
+}
+
+// YNSavePrompt creates a yes or no prompt which also accepts 's' to save and
+// then answer yes. The callback returns the yes/no result, whether save was
+// chosen and whether the prompt was canceled
+func (i *InfoBuf) YNSavePrompt(prompt string, donecb func(bool, bool, bool)) {
+	i.YNPrompt(prompt, func(yes, canceled bool) {
+		donecb(yes, i.YNSave, canceled)
+	})
+	i.HasYNSave = true
+}
+
 // DonePrompt finishes the current prompt and indicates whether or not it was canceled
 func (i *InfoBuf) DonePrompt(canceled bool) {
 	hadYN := i.HasYN
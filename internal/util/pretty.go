@@ -0,0 +1,123 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+)
+
+// PrettyLanguages are the languages supported by Pretty and Minify
+var PrettyLanguages = []string{"json", "xml"}
+
+// Pretty reformats JSON or XML text with one element per line, indented with
+// the given string
+func Pretty(lang, text, indent string) (string, error) {
+	switch lang {
+	case "json":
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, []byte(text), "", indent); err != nil {
+			return "", errors.New("Invalid JSON: " + err.Error())
+		}
+		return buf.String(), nil
+	case "xml":
+		return reformatXML(text, indent)
+	}
+	return "", errors.New("Unknown language " + lang)
+}
+
+// Minify removes the insignificant whitespace from JSON or XML text
+func Minify(lang, text string) (string, error) {
+	switch lang {
+	case "json":
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, []byte(text)); err != nil {
+			return "", errors.New("Invalid JSON: " + err.Error())
+		}
+		return buf.String(), nil
+	case "xml":
+		return reformatXML(text, "")
+	}
+	return "", errors.New("Unknown language " + lang)
+}
+
+// reformatXML re-encodes XML text without its whitespace-only text, indented
+// with the given string if it is not empty
+func reformatXML(text, indent string) (string, error) {
+	// raw tokens keep the namespace prefixes as written, they are put back in
+	// the local names so that the encoder doesn't try to resolve them
+	rawName := func(n xml.Name) xml.Name {
+		if n.Space != "" {
+			return xml.Name{Local: n.Space + ":" + n.Local}
+		}
+		return n
+	}
+
+	d := xml.NewDecoder(strings.NewReader(text))
+	var buf bytes.Buffer
+	e := xml.NewEncoder(&buf)
+	if indent != "" {
+		e.Indent("", indent)
+	}
+
+	depth := 0
+	// the encoder doesn't indent comments, processing instructions and
+	// directives, so this is done here
+	wroteElement, pendingNewline := false, false
+	for {
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return "", errors.New("Invalid XML: " + err.Error())
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if pendingNewline {
+				e.Flush()
+				buf.WriteString("\n")
+				pendingNewline = false
+			}
+			wroteElement = true
+			depth++
+			t.Name = rawName(t.Name)
+			attrs := make([]xml.Attr, len(t.Attr))
+			for i, a := range t.Attr {
+				attrs[i] = xml.Attr{Name: rawName(a.Name), Value: a.Value}
+			}
+			t.Attr = attrs
+			tok = t
+		case xml.EndElement:
+			depth--
+			if depth < 0 {
+				return "", errors.New("Invalid XML: unexpected end element </" + t.Name.Local + ">")
+			}
+			tok = xml.EndElement{Name: rawName(t.Name)}
+		case xml.CharData:
+			if len(bytes.TrimSpace(t)) == 0 {
+				continue
+			}
+		case xml.Comment, xml.ProcInst, xml.Directive:
+			if indent != "" {
+				e.Flush()
+				if buf.Len() > 0 {
+					buf.WriteString("\n" + strings.Repeat(indent, depth))
+				}
+				pendingNewline = !wroteElement
+			}
+		}
+		if err := e.EncodeToken(xml.CopyToken(tok)); err != nil {
+			return "", errors.New("Invalid XML: " + err.Error())
+		}
+	}
+	if depth != 0 {
+		return "", errors.New("Invalid XML: unclosed element")
+	}
+	if err := e.Flush(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,121 @@
+package util
+
+import (
+	"errors"
+	"io/fs"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ErrWalkLimit is returned by WalkProject when it stops early because the
+// project has too many files
+var ErrWalkLimit = errors.New("too many files")
+
+// An ignoreRule is a pattern of a .gitignore file
+type ignoreRule struct {
+	// dir is the directory of the .gitignore file, relative to the root
+	dir      string
+	pattern  string
+	dirOnly  bool
+	anchored bool
+}
+
+func (r ignoreRule) match(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if r.dir != "." {
+		if !strings.HasPrefix(rel, r.dir+"/") {
+			return false
+		}
+		rel = rel[len(r.dir)+1:]
+	}
+	if !r.anchored {
+		rel = path.Base(rel)
+	}
+	ok, _ := path.Match(r.pattern, rel)
+	return ok
+}
+
+// readIgnoreRules parses the .gitignore file of the given directory, if any.
+// Negated patterns are not supported and are skipped
+func readIgnoreRules(root, dir string) []ignoreRule {
+	data, err := ioutil.ReadFile(filepath.Join(root, filepath.FromSlash(dir), ".gitignore"))
+	if err != nil {
+		return nil
+	}
+
+	var rules []ignoreRule
+	for _, line := range strings.Split(string(data), "\n") {
+		p := strings.TrimSpace(line)
+		if p == "" || strings.HasPrefix(p, "#") || strings.HasPrefix(p, "!") {
+			continue
+		}
+		r := ignoreRule{dir: dir}
+		if strings.HasSuffix(p, "/") {
+			r.dirOnly = true
+			p = strings.TrimSuffix(p, "/")
+		}
+		p = strings.TrimPrefix(p, "**/")
+		r.anchored = strings.Contains(p, "/")
+		r.pattern = strings.TrimPrefix(p, "/")
+		rules = append(rules, r)
+	}
+	return rules
+}
+
+// WalkProject calls fn with the path, relative to root and using slashes,
+// of every file in the directory tree of root. The .git directories and the
+// paths ignored by .gitignore files are skipped. The walk stops with
+// ErrWalkLimit after max files
+func WalkProject(root string, max int, fn func(rel string)) error {
+	var rules []ignoreRule
+	n := 0
+	return filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// skip unreadable files and directories
+			if d != nil && d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			if rel != "." && isIgnored(rules, rel, true) {
+				return filepath.SkipDir
+			}
+			rules = append(rules, readIgnoreRules(root, rel)...)
+			return nil
+		}
+
+		if isIgnored(rules, rel, false) {
+			return nil
+		}
+		if n >= max {
+			return ErrWalkLimit
+		}
+		n++
+		fn(rel)
+		return nil
+	})
+}
+
+func isIgnored(rules []ignoreRule, rel string, isDir bool) bool {
+	for _, r := range rules {
+		if r.match(rel, isDir) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package util
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A SnippetStop is a tab stop of a snippet, as character offsets in its text
+type SnippetStop struct {
+	Index      int
+	Start, End int
+}
+
+// ParseSnippet returns the text of a snippet body without its tab stop
+// markers, and its tab stops ordered by index, with $0 (the final position of
+// the cursor) last. Tab stops are written $N or ${N:default}, and \$ is a
+// literal dollar sign. If there is no $0, it is put at the end of the text.
+// Only the first tab stop of each index is kept
+func ParseSnippet(body string) (string, []SnippetStop) {
+	var text strings.Builder
+	var stops []SnippetStop
+	seen := make(map[int]bool)
+	n := 0 // characters written to text
+
+	addStop := func(index int, def string) {
+		if !seen[index] {
+			seen[index] = true
+			stops = append(stops, SnippetStop{index, n, n + CharacterCountInString(def)})
+		}
+		text.WriteString(def)
+		n += CharacterCountInString(def)
+	}
+
+	r := []rune(body)
+	for i := 0; i < len(r); i++ {
+		if r[i] == '\\' && i+1 < len(r) && r[i+1] == '$' {
+			text.WriteRune('$')
+			n++
+			i++
+			continue
+		}
+		if r[i] == '$' {
+			j := i + 1
+			braced := j < len(r) && r[j] == '{'
+			if braced {
+				j++
+			}
+			k := j
+			for k < len(r) && r[k] >= '0' && r[k] <= '9' {
+				k++
+			}
+			if k > j {
+				index, _ := strconv.Atoi(string(r[j:k]))
+				if !braced {
+					addStop(index, "")
+					i = k - 1
+					continue
+				}
+				def := ""
+				if k < len(r) && r[k] == ':' {
+					end := k + 1
+					for end < len(r) && r[end] != '}' {
+						end++
+					}
+					def, k = string(r[k+1:end]), end
+				}
+				if k < len(r) && r[k] == '}' {
+					addStop(index, def)
+					i = k
+					continue
+				}
+			}
+		}
+		text.WriteRune(r[i])
+		n++
+	}
+
+	if !seen[0] {
+		stops = append(stops, SnippetStop{0, n, n})
+	}
+	sort.SliceStable(stops, func(a, b int) bool {
+		if stops[a].Index == 0 || stops[b].Index == 0 {
+			return stops[b].Index == 0 && stops[a].Index != 0
+		}
+		return stops[a].Index < stops[b].Index
+	})
+	return text.String(), stops
+}
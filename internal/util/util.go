@@ -3,6 +3,8 @@ package util
 import (
 	"archive/zip"
 	"bytes"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
@@ -12,6 +14,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -312,6 +315,53 @@ func ReplaceHome(path string) (string, error) {
 	return strings.Replace(path, homeString, home, 1), nil
 }
 
+// CollapseHome replaces the user's home directory at the start of the path
+// with ~. This is the reverse of ReplaceHome
+func CollapseHome(path string) string {
+	userData, err := user.Current()
+	if err != nil || userData.HomeDir == "" {
+		return path
+	}
+	home := userData.HomeDir
+	if path == home {
+		return "~"
+	}
+	if strings.HasPrefix(path, home+string(filepath.Separator)) {
+		return "~" + path[len(home):]
+	}
+	return path
+}
+
+// FuzzyMatch returns whether all the characters of the pattern appear in the
+// string in the same order, ignoring case. For example "mcgo" matches
+// "cmd/macro/macro.go"
+func FuzzyMatch(pattern, str string) bool {
+	str = strings.ToLower(str)
+	for _, r := range strings.ToLower(pattern) {
+		i := strings.IndexRune(str, r)
+		if i < 0 {
+			return false
+		}
+		str = str[i+utf8.RuneLen(r):]
+	}
+	return true
+}
+
+// CommonPrefix returns the longest prefix shared by all the given strings
+func CommonPrefix(strs []string) string {
+	if len(strs) == 0 {
+		return ""
+	}
+	prefix := strs[0]
+	for _, s := range strs[1:] {
+		for !strings.HasPrefix(s, prefix) {
+			_, size := utf8.DecodeLastRuneInString(prefix)
+			prefix = prefix[:len(prefix)-size]
+		}
+	}
+	return prefix
+}
+
 // GetPathAndCursorPosition returns a filename without everything following a `:`
 // This is used for opening files like util.go:10:5 to specify a line and column
 // Special cases like Windows Absolute path (C:\myfile.txt:10:5) are handled correctly.
@@ -329,6 +379,45 @@ func GetPathAndCursorPosition(path string) (string, []string) {
 	return match[1], []string{match[2], "0"}
 }
 
+// ExpandTemplate expands the placeholders of a file name template:
+// {date} is replaced with the given date (as YYYY-MM-DD), {base} with the given
+// base name, and {n} with the smallest positive number for which the resulting
+// path does not exist yet
+func ExpandTemplate(tmpl, base string, now time.Time, exists func(string) bool) string {
+	path := strings.ReplaceAll(tmpl, "{date}", now.Format("2006-01-02"))
+	path = strings.ReplaceAll(path, "{base}", base)
+	if !strings.Contains(path, "{n}") {
+		return path
+	}
+
+	for n := 1; ; n++ {
+		p := strings.ReplaceAll(path, "{n}", strconv.Itoa(n))
+		if !exists(p) {
+			return p
+		}
+	}
+}
+
+// TimestampFormats are the named formats understood by FormatTimestamp
+var TimestampFormats = map[string]string{
+	"date":     "2006-01-02",
+	"time":     "15:04:05",
+	"datetime": time.RFC3339,
+}
+
+// FormatTimestamp formats the given time with a named format of
+// TimestampFormats, "unix" for the number of seconds since the epoch, or
+// else a Go time layout such as "02/01/2006 15:04"
+func FormatTimestamp(format string, t time.Time) string {
+	if format == "unix" {
+		return strconv.FormatInt(t.Unix(), 10)
+	}
+	if layout, ok := TimestampFormats[format]; ok {
+		format = layout
+	}
+	return t.Format(format)
+}
+
 // GetModTime returns the last modification time for a given file
 func GetModTime(path string) (time.Time, error) {
 	info, err := os.Stat(path)
@@ -386,6 +475,199 @@ func HasTrailingWhitespace(b []byte) bool {
 	return IsWhitespace(r)
 }
 
+// Reindent re-emits the given leading whitespace in the target indentation
+// style. The visual width of the whitespace is split into indentation levels
+// of tabsize columns, and any leftover columns are kept as spaces
+func Reindent(ws []byte, tabsize int, tabstospaces bool) []byte {
+	width := StringWidth(ws, CharacterCount(ws), tabsize)
+	if tabstospaces {
+		return bytes.Repeat([]byte{' '}, width)
+	}
+	indent := bytes.Repeat([]byte{'\t'}, width/tabsize)
+	return append(indent, bytes.Repeat([]byte{' '}, width%tabsize)...)
+}
+
+// IndentGuides returns the visual columns at which indent guides should
+// be drawn for the given line: one for each indentation level of its
+// leading whitespace
+func IndentGuides(line []byte, tabsize int) []int {
+	ws := GetLeadingWhitespace(line)
+	if len(ws) == len(line) || tabsize <= 0 {
+		return nil
+	}
+
+	width := StringWidth(ws, CharacterCount(ws), tabsize)
+	var guides []int
+	for col := 0; col < width; col += tabsize {
+		guides = append(guides, col)
+	}
+	return guides
+}
+
+// BlankLineGuides returns the indent guides of a blank line between the
+// lines prev and next, which are the closest non-blank lines around it: the
+// guides that both lines have, so that the guides of a block continue
+// through its blank lines
+func BlankLineGuides(prev, next []byte, tabsize int) []int {
+	p, n := IndentGuides(prev, tabsize), IndentGuides(next, tabsize)
+	if len(p) < len(n) {
+		return p
+	}
+	return n
+}
+
+// ReverseLines returns the given lines in reverse order
+func ReverseLines(lines []string) []string {
+	reversed := make([]string, len(lines))
+	for i, l := range lines {
+		reversed[len(lines)-1-i] = l
+	}
+	return reversed
+}
+
+// ToggleCase swaps the case of every letter of the given string
+func ToggleCase(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsUpper(r) {
+			return unicode.ToLower(r)
+		}
+		return unicode.ToUpper(r)
+	}, s)
+}
+
+// SortLinesFunc sorts lines with the given less function. Equal lines keep
+// their order
+func SortLinesFunc(lines []string, less func(a, b string) bool) []string {
+	sorted := append([]string(nil), lines...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return less(sorted[i], sorted[j])
+	})
+	return sorted
+}
+
+// NaturalLess compares two strings like a < b, except that the numbers they
+// contain are compared by value, so that line2 comes before line10
+func NaturalLess(a, b string) bool {
+	for a != "" && b != "" {
+		if isDigit(a[0]) && isDigit(b[0]) {
+			na, nb := digitsPrefix(a), digitsPrefix(b)
+			ta, tb := strings.TrimLeft(na, "0"), strings.TrimLeft(nb, "0")
+			if len(ta) != len(tb) {
+				return len(ta) < len(tb)
+			}
+			if ta != tb {
+				return ta < tb
+			}
+			a, b = a[len(na):], b[len(nb):]
+			continue
+		}
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) < len(b)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// digitsPrefix returns the digits at the start of s
+func digitsPrefix(s string) string {
+	i := 0
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+	return s[:i]
+}
+
+var leadingNumberRegexp = regexp.MustCompile(`^\s*([+-]?\d+(\.\d+)?)`)
+
+// SortLinesNumeric sorts lines by the number they start with (ignoring
+// leading whitespace). Lines which don't start with a number are kept in
+// their order after the others, and so are lines with the same number
+func SortLinesNumeric(lines []string) []string {
+	type numbered struct {
+		line  string
+		n     float64
+		isNum bool
+	}
+	nums := make([]numbered, len(lines))
+	for i, l := range lines {
+		nums[i].line = l
+		if m := leadingNumberRegexp.FindStringSubmatch(l); m != nil {
+			nums[i].n, _ = strconv.ParseFloat(m[1], 64)
+			nums[i].isNum = true
+		}
+	}
+
+	sort.SliceStable(nums, func(i, j int) bool {
+		if nums[i].isNum != nums[j].isNum {
+			return nums[i].isNum
+		}
+		return nums[i].isNum && nums[i].n < nums[j].n
+	})
+
+	sorted := make([]string, len(nums))
+	for i, n := range nums {
+		sorted[i] = n.line
+	}
+	return sorted
+}
+
+// Encodings are the encodings supported by Encode and Decode
+var Encodings = []string{"base64", "hex"}
+
+// Encode encodes the given text with one of the Encodings
+func Encode(encoding, text string) (string, error) {
+	switch encoding {
+	case "base64":
+		return base64.StdEncoding.EncodeToString([]byte(text)), nil
+	case "hex":
+		return hex.EncodeToString([]byte(text)), nil
+	}
+	return "", errors.New("Unknown encoding " + encoding)
+}
+
+// Decode decodes the given text with one of the Encodings. Surrounding
+// whitespace is ignored
+func Decode(encoding, text string) (string, error) {
+	var data []byte
+	var err error
+	text = strings.TrimSpace(text)
+	switch encoding {
+	case "base64":
+		data, err = base64.StdEncoding.DecodeString(text)
+	case "hex":
+		data, err = hex.DecodeString(text)
+	default:
+		return "", errors.New("Unknown encoding " + encoding)
+	}
+	if err != nil {
+		return "", fmt.Errorf("Invalid %s: %s", encoding, err)
+	}
+	return string(data), nil
+}
+
+var tagRegexp = regexp.MustCompile(`^<([A-Za-z][\w-]*)(\s[^<>]*[^/<>])?>$`)
+
+// SurroundParts splits a surround template into the text to insert before and
+// after the surrounded text. The template is split on its %s placeholder.
+// Without a placeholder, an opening tag such as <div class="x"> is closed with
+// </div>, and any other template is inserted on both sides. The \n escape
+// sequence can be used to insert newlines
+func SurroundParts(tmpl string) (string, string) {
+	tmpl = strings.ReplaceAll(tmpl, "\\n", "\n")
+	if i := strings.Index(tmpl, "%s"); i >= 0 {
+		return tmpl[:i], tmpl[i+2:]
+	}
+	if m := tagRegexp.FindStringSubmatch(tmpl); m != nil {
+		return tmpl, "</" + m[1] + ">"
+	}
+	return tmpl, tmpl
+}
+
 // IntOpt turns a float64 setting to an int
 func IntOpt(opt interface{}) int {
 	return int(opt.(float64))
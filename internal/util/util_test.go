@@ -1,7 +1,11 @@
 package util
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -31,3 +35,257 @@ func TestSliceVisualEnd(t *testing.T) {
 	assert.Equal(t, []byte("ello"), slc)
 	assert.Equal(t, 0, n)
 }
+
+func TestReindent(t *testing.T) {
+	assert.Equal(t, []byte("\t\t"), Reindent([]byte("\t    "), 4, false))
+	assert.Equal(t, []byte("\t  "), Reindent([]byte("  \t  "), 4, false))
+	assert.Equal(t, []byte("        "), Reindent([]byte("\t    "), 4, true))
+	assert.Equal(t, []byte("      "), Reindent([]byte("  \t  "), 4, true))
+	assert.Equal(t, []byte("\t"), Reindent([]byte("  \t"), 4, false))
+	assert.Equal(t, []byte{}, Reindent([]byte{}, 4, false))
+}
+
+func TestGetCharPosInLine(t *testing.T) {
+	b := []byte("\tab\tc")
+
+	// display columns land on a different character than the same
+	// character index when the line contains tabs
+	assert.Equal(t, 0, GetCharPosInLine(b, 0, 4))
+	assert.Equal(t, 0, GetCharPosInLine(b, 2, 4))
+	assert.Equal(t, 1, GetCharPosInLine(b, 4, 4))
+	assert.Equal(t, 2, GetCharPosInLine(b, 5, 4))
+	assert.Equal(t, 3, GetCharPosInLine(b, 6, 4))
+	assert.Equal(t, 4, GetCharPosInLine(b, 8, 4))
+	assert.Equal(t, 5, GetCharPosInLine(b, 20, 4))
+
+	assert.Equal(t, 2, GetCharPosInLine([]byte("世界a"), 4, 4))
+}
+
+func TestExpandTemplate(t *testing.T) {
+	now := time.Date(2024, 3, 9, 12, 0, 0, 0, time.UTC)
+	existing := map[string]bool{
+		"notes-1.md": true,
+		"notes-2.md": true,
+	}
+	exists := func(p string) bool {
+		return existing[p]
+	}
+
+	assert.Equal(t, "notes-2024-03-09.md", ExpandTemplate("{base}-{date}.md", "notes", now, exists))
+	assert.Equal(t, "notes-3.md", ExpandTemplate("{base}-{n}.md", "notes", now, exists))
+	assert.Equal(t, "todo-1.md", ExpandTemplate("todo-{n}.md", "notes", now, exists))
+	assert.Equal(t, "plain.txt", ExpandTemplate("plain.txt", "notes", now, exists))
+	assert.Equal(t, "{x}/notes", ExpandTemplate("{x}/{base}", "notes", now, exists))
+}
+
+func TestReverseLines(t *testing.T) {
+	assert.Equal(t, []string{"c", "b", "a"}, ReverseLines([]string{"a", "b", "c"}))
+	assert.Equal(t, []string{}, ReverseLines([]string{}))
+}
+
+func TestSurroundParts(t *testing.T) {
+	surround := func(tmpl, text string) string {
+		before, after := SurroundParts(tmpl)
+		return before + text + after
+	}
+	assert.Equal(t, "**foo**", surround("**", "foo"))
+	assert.Equal(t, "(foo)", surround("(%s)", "foo"))
+	assert.Equal(t, "```\nfoo\n```", surround("```\\n%s\\n```", "foo"))
+	assert.Equal(t, "<b>foo</b>", surround("<b>", "foo"))
+	assert.Equal(t, `<a href="x">foo</a>`, surround(`<a href="x">`, "foo"))
+	assert.Equal(t, "<br/>foo<br/>", surround("<br/>", "foo"))
+	assert.Equal(t, "<br />foo<br />", surround("<br />", "foo"))
+	assert.Equal(t, "{{foo}} %s", surround("{{%s}} %s", "foo"))
+}
+
+func TestIndentGuides(t *testing.T) {
+	assert.Equal(t, []int{0, 4}, IndentGuides([]byte("        foo"), 4))
+	assert.Equal(t, []int{0, 4, 8}, IndentGuides([]byte("\t\t  foo"), 4))
+	assert.Equal(t, []int{0, 2, 4}, IndentGuides([]byte("    \tfoo"), 2))
+	assert.Equal(t, []int{0}, IndentGuides([]byte("  foo"), 4))
+	assert.Nil(t, IndentGuides([]byte("foo"), 4))
+	assert.Nil(t, IndentGuides([]byte("        "), 4))
+}
+
+func TestBlankLineGuides(t *testing.T) {
+	assert.Equal(t, []int{0, 4}, BlankLineGuides([]byte("\t\tfoo"), []byte("        bar"), 4))
+	assert.Equal(t, []int{0}, BlankLineGuides([]byte("\tfoo"), []byte("\t\tbar"), 4))
+	assert.Equal(t, []int{0}, BlankLineGuides([]byte("\t\tfoo"), []byte("\tbar"), 4))
+	assert.Nil(t, BlankLineGuides([]byte("foo"), []byte("\tbar"), 4))
+	assert.Nil(t, BlankLineGuides(nil, []byte("\tbar"), 4))
+}
+
+func TestFuzzyMatch(t *testing.T) {
+	assert.True(t, FuzzyMatch("mcgo", "cmd/macro/macro.go"))
+	assert.True(t, FuzzyMatch("CMD", "cmd/macro/macro.go"))
+	assert.True(t, FuzzyMatch("", "foo"))
+	assert.True(t, FuzzyMatch("ă", "mănânc"))
+	assert.False(t, FuzzyMatch("og", "go"))
+	assert.False(t, FuzzyMatch("foox", "foo"))
+}
+
+func TestWalkProject(t *testing.T) {
+	root := t.TempDir()
+	for _, f := range []string{
+		"main.go", "README.md", "debug.log",
+		".git/config",
+		"build/out.bin",
+		"src/app.go", "src/app.tmp", "src/gen/x.go", "src/keep/gen/y.go",
+		"docs/.gitignore",
+		"docs/a.md", "docs/draft.md",
+	} {
+		p := filepath.Join(root, filepath.FromSlash(f))
+		os.MkdirAll(filepath.Dir(p), 0755)
+		os.WriteFile(p, nil, 0644)
+	}
+	os.WriteFile(filepath.Join(root, ".gitignore"), []byte("# comment\n*.log\nbuild/\n**/*.tmp\n/src/gen\n"), 0644)
+	os.WriteFile(filepath.Join(root, "docs", ".gitignore"), []byte("draft.md\n"), 0644)
+
+	var files []string
+	err := WalkProject(root, 100, func(rel string) {
+		files = append(files, rel)
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		".gitignore", "README.md",
+		"docs/.gitignore", "docs/a.md",
+		"main.go",
+		"src/app.go", "src/keep/gen/y.go",
+	}, files)
+
+	files = nil
+	err = WalkProject(root, 2, func(rel string) {
+		files = append(files, rel)
+	})
+	assert.Equal(t, ErrWalkLimit, err)
+	assert.Len(t, files, 2)
+}
+
+func TestFormatTimestamp(t *testing.T) {
+	now := time.Date(2024, time.March, 5, 14, 7, 9, 0, time.FixedZone("CET", 3600))
+	assert.Equal(t, "2024-03-05", FormatTimestamp("date", now))
+	assert.Equal(t, "14:07:09", FormatTimestamp("time", now))
+	assert.Equal(t, "2024-03-05T14:07:09+01:00", FormatTimestamp("datetime", now))
+	assert.Equal(t, "1709644029", FormatTimestamp("unix", now))
+	assert.Equal(t, "05/03/2024 14:07", FormatTimestamp("02/01/2006 15:04", now))
+}
+
+func TestToggleCase(t *testing.T) {
+	assert.Equal(t, "fOObAR", ToggleCase("FooBar"))
+	assert.Equal(t, "ÉTÉ été 42", ToggleCase("été ÉTÉ 42"))
+}
+
+func TestSortLinesFunc(t *testing.T) {
+	assert.Equal(t,
+		[]string{"line1", "line2", "line10", "Line10b"},
+		SortLinesFunc([]string{"line10", "Line10b", "line2", "line1"}, func(a, b string) bool {
+			return NaturalLess(strings.ToLower(a), strings.ToLower(b))
+		}))
+}
+
+func TestNaturalLess(t *testing.T) {
+	assert.True(t, NaturalLess("line2", "line10"))
+	assert.False(t, NaturalLess("line10", "line2"))
+	assert.True(t, NaturalLess("a1b2", "a1b10"))
+	assert.True(t, NaturalLess("v1.9", "v1.10"))
+	assert.True(t, NaturalLess("a", "b"))
+	assert.True(t, NaturalLess("a", "a1"))
+	assert.False(t, NaturalLess("x007", "x7"))
+	assert.False(t, NaturalLess("x7", "x007"))
+}
+
+func TestSortLinesNumeric(t *testing.T) {
+	assert.Equal(t,
+		[]string{"-3 c", "2 b", "  10 a", "10.5 d"},
+		SortLinesNumeric([]string{"  10 a", "2 b", "-3 c", "10.5 d"}))
+
+	// lines without number go last, in their original order, and so do
+	// lines with the same number
+	assert.Equal(t,
+		[]string{"1 x", "2 first", "2 second", "foo", "", "bar 1"},
+		SortLinesNumeric([]string{"foo", "2 first", "", "1 x", "bar 1", "2 second"}))
+}
+
+func TestEncode(t *testing.T) {
+	for _, enc := range Encodings {
+		for _, text := range []string{"", "hello, world", "héllo\n\x00"} {
+			encoded, err := Encode(enc, text)
+			assert.Nil(t, err)
+			decoded, err := Decode(enc, encoded)
+			assert.Nil(t, err)
+			assert.Equal(t, text, decoded)
+		}
+	}
+
+	s, _ := Encode("base64", "foo")
+	assert.Equal(t, "Zm9v", s)
+	s, _ = Encode("hex", "foo")
+	assert.Equal(t, "666f6f", s)
+	s, _ = Decode("base64", " Zm9v\n")
+	assert.Equal(t, "foo", s)
+
+	_, err := Decode("base64", "Zm9v!")
+	assert.NotNil(t, err)
+	_, err = Decode("hex", "66f")
+	assert.NotNil(t, err)
+	_, err = Decode("hex", "zz")
+	assert.NotNil(t, err)
+	_, err = Encode("rot13", "foo")
+	assert.NotNil(t, err)
+}
+
+func TestPrettyJSON(t *testing.T) {
+	compact := `{"b":[1,2,{"c":null}],"a":"x y"}`
+	pretty, err := Pretty("json", compact, "  ")
+	assert.Nil(t, err)
+	assert.Equal(t, "{\n  \"b\": [\n    1,\n    2,\n    {\n      \"c\": null\n    }\n  ],\n  \"a\": \"x y\"\n}", pretty)
+
+	min, err := Minify("json", pretty)
+	assert.Nil(t, err)
+	assert.Equal(t, compact, min)
+
+	_, err = Pretty("json", `{"a": 1,}`, "\t")
+	assert.NotNil(t, err)
+	_, err = Minify("json", `[1, 2`)
+	assert.NotNil(t, err)
+}
+
+func TestPrettyXML(t *testing.T) {
+	compact := `<?xml version="1.0"?><a xmlns:x="urn:x"><x:b id="1">text &amp; more</x:b><!-- c --><d/></a>`
+	pretty, err := Pretty("xml", compact, "\t")
+	assert.Nil(t, err)
+	assert.Equal(t, "<?xml version=\"1.0\"?>\n<a xmlns:x=\"urn:x\">\n\t<x:b id=\"1\">text &amp; more</x:b>\n\t<!-- c -->\n\t<d></d>\n</a>", pretty)
+
+	min, err := Minify("xml", pretty)
+	assert.Nil(t, err)
+	assert.Equal(t, `<?xml version="1.0"?><a xmlns:x="urn:x"><x:b id="1">text &amp; more</x:b><!-- c --><d></d></a>`, min)
+
+	_, err = Pretty("xml", "<a><b></a>", "\t")
+	assert.NotNil(t, err)
+	_, err = Minify("xml", "<a>")
+	assert.NotNil(t, err)
+}
+
+func TestParseSnippet(t *testing.T) {
+	text, stops := ParseSnippet("for ${1:i}, ${2:v} := range $3 {\n\t$0\n}")
+	assert.Equal(t, "for i, v := range  {\n\t\n}", text)
+	assert.Equal(t, []SnippetStop{{1, 4, 5}, {2, 7, 8}, {3, 18, 18}, {0, 22, 22}}, stops)
+
+	// $0 defaults to the end, escaped and lone dollars are kept
+	text, stops = ParseSnippet(`echo \$HOME $ ${2:b} ${1}`)
+	assert.Equal(t, "echo $HOME $ b ", text)
+	assert.Equal(t, []SnippetStop{{1, 15, 15}, {2, 13, 14}, {0, 15, 15}}, stops)
+
+	text, stops = ParseSnippet("plain")
+	assert.Equal(t, "plain", text)
+	assert.Equal(t, []SnippetStop{{0, 5, 5}}, stops)
+}
+
+func TestCommonPrefix(t *testing.T) {
+	assert.Equal(t, "save", CommonPrefix([]string{"save", "saveall", "savetemplate"}))
+	assert.Equal(t, "s", CommonPrefix([]string{"set", "save"}))
+	assert.Equal(t, "", CommonPrefix([]string{"set", "quit"}))
+	assert.Equal(t, "héllo", CommonPrefix([]string{"héllo"}))
+	assert.Equal(t, "h", CommonPrefix([]string{"hé", "hè"}))
+	assert.Equal(t, "", CommonPrefix(nil))
+}
@@ -42,6 +42,11 @@ function onRune(bp, r)
 end
 
 function preInsertNewline(bp)
+    if bp.Buf.Settings["autoindent"] and bp.Buf.Settings["smartindent"] then
+        -- the closing bracket is already moved to its own line
+        return true
+    end
+
     local curLine = bp.Buf:Line(bp.Cursor.Y)
     local curRune = charAt(curLine, bp.Cursor.X)
     local nextRune = charAt(curLine, bp.Cursor.X+1)
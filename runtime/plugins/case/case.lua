@@ -1,16 +1,49 @@
 AUTHOR = "shkschneider/macro"
 NAME = "case"
-VERSION = "1.2.0"
+VERSION = "1.3.0"
 
 local micro = import("micro")
 local config = import("micro/config")
 local buffer = import("micro/buffer")
 local util = import("micro/util")
+local strings = import("strings")
+local regexp = import("regexp")
+local utf8 = import("utf8")
 
 local cases = { "camel", "pascal", "kebab", "snake", "upper", "lower", "reverse", "increment", "decrement", "title" }
 
+-- words are made of letters and digits of any script
+local words = regexp.MustCompile("[\\p{L}\\p{N}]+")
+
+-- capitalize upper cases the first letter of the word, and lower cases the
+-- rest of it unless keep is set
+local function capitalize(w, keep)
+    local _, size = utf8.DecodeRuneInString(w)
+    local rest = w:sub(size + 1)
+    if not keep then
+        rest = strings.ToLower(rest)
+    end
+    return strings.ToUpper(w:sub(1, size)) .. rest
+end
+
+local function pascal(s)
+    local found, t = words:FindAllString(s, -1), {}
+    for i = 1, #found do
+        t[i] = capitalize(found[i])
+    end
+    return table.concat(t)
+end
+
 function Case(bp, args)
     local c = bp.Cursor
+    if bp.Buf.Type.Readonly then
+        micro.InfoBar():Error("Cannot modify a read-only buffer")
+        return
+    end
+    if not c:HasSelection() then
+        -- default to the word under the cursor
+        c:SelectWord()
+    end
     if not c:HasSelection() then
         micro.InfoBar():Error("No selection")
         return
@@ -19,32 +52,27 @@ function Case(bp, args)
     local case, s = tostring(args[1]), nil
     if case == "c" or case == "camel" then
         micro.InfoBar():GutterMessage("camelCase")
-        local p = selection:gsub("[^%w]", " "):gsub("(%w+)", function (w)
-            return w:sub(1, 1):upper() .. w:sub(2, -1):lower()
-        end):gsub("%s+", "")
-        s = p:sub(1, 1):lower() .. p:sub(2, -1)
+        local p = pascal(selection)
+        local _, size = utf8.DecodeRuneInString(p)
+        s = strings.ToLower(p:sub(1, size)) .. p:sub(size + 1)
     elseif case == "p" or case == "pascal" then
         micro.InfoBar():GutterMessage("PascalCase")
-        s = selection:gsub("[^%w]", " "):gsub("(%w+)", function (w)
-            return w:sub(1, 1):upper() .. w:sub(2, -1):lower()
-        end):gsub("%s+", "")
+        s = pascal(selection)
     elseif case == "k" or case == "kebab" then
         micro.InfoBar():GutterMessage("kebab-case")
-        s = selection:lower():gsub("[^%w]", "_"):gsub("_+", "-")
+        s = strings.ToLower(strings.Join(words:FindAllString(selection, -1), "-"))
     elseif case == "s" or case == "snake" then
         micro.InfoBar():GutterMessage("snake_case")
-        s = selection:lower():gsub("[^%w]", "_"):gsub("__+", "_")
+        s = strings.ToLower(strings.Join(words:FindAllString(selection, -1), "_"))
     elseif case == "u" or case == "upper" then
         micro.InfoBar():GutterMessage("UPPERCASE")
-        s = selection:upper()
+        s = strings.ToUpper(selection)
     elseif case == "l" or case == "lower" then
         micro.InfoBar():GutterMessage("lowercase")
-        s = selection:lower()
+        s = strings.ToLower(selection)
     elseif case == "r" or case == "reverse" then
         micro.InfoBar():GutterMessage("rEVERSEcASE")
-        s = selection:gsub("%a", function (c)
-            if c == c:upper() then return c:lower() else return c:upper() end
-        end)
+        s = util.ToggleCase(selection)
     elseif case == "i" or case == "increment" then
         micro.InfoBar():GutterMessage("increment")
         s = tostring(tonumber(selection) + 1)
@@ -53,12 +81,18 @@ function Case(bp, args)
         s = tostring(tonumber(selection) - 1)
     elseif case == "t" or case == "title" then
         micro.InfoBar():GutterMessage("Title case")
-        s = selection:gsub("%a+", function(char) return char:sub(1, 1):upper() .. char:sub(2) end)
+        s = words:ReplaceAllStringFunc(selection, function (w)
+            return capitalize(w, true)
+        end)
     else
         return micro.InfoBar():Error("Not implemented: " .. tostring(case))
     end
-    bp.Cursor:DeleteSelection()
-    bp.Buf:Insert(buffer.Loc(bp.Cursor.Loc.X, bp.Cursor.Loc.Y), s)
+    c:DeleteSelection()
+    local start = buffer.Loc(c.Loc.X, c.Loc.Y)
+    bp.Buf:Insert(start, s)
+    -- keep the transformed text selected
+    c:SetSelectionStart(start)
+    c:SetSelectionEnd(c.Loc)
 end
 
 function CaseComplete()
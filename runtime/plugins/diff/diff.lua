@@ -1,8 +1,15 @@
 VERSION = "1.0.0"
 
+local micro = import("micro")
+local config = import("micro/config")
 local os = import("os")
 local filepath = import("path/filepath")
 local shell = import("micro/shell")
+local time = import("time")
+
+function init()
+	config.MakeCommand("blame", blame, config.NoComplete)
+end
 
 function onBufferOpen(buf)
 	if buf.Settings["diffgutter"] and (not buf.Type.Scratch) and (buf.Path ~= "") then
@@ -18,3 +25,37 @@ function onBufferOpen(buf)
 		end
 	end
 end
+
+-- blame shows the commit which last changed the line of the cursor, in the
+-- background so that big histories don't block the editor
+function blame(bp, args)
+	if bp.Buf.Path == "" then
+		micro.InfoBar():Error("The buffer has no file")
+		return
+	end
+	local dirName, fileName = filepath.Split(bp.Buf.AbsPath)
+	local line = tostring(bp.Cursor.Y + 1)
+	shell.JobSpawn("git", {"-C", dirName, "blame", "--porcelain", "-L", line .. "," .. line, "--", fileName}, nil, nil, onBlameExit, line)
+end
+
+function onBlameExit(output, args)
+	local line = args[1]
+	local hash = output:match("^(%x+) %d+ %d+")
+	if hash == nil then
+		micro.InfoBar():Error("No blame for line " .. line .. ": the file is not tracked by Git")
+		return
+	end
+	if hash:match("^0+$") then
+		micro.InfoBar():Message("Line " .. line .. ": not committed yet")
+		return
+	end
+
+	local author = output:match("\nauthor ([^\n]*)") or ""
+	local date = ""
+	local seconds = tonumber(output:match("\nauthor%-time (%d+)"))
+	if seconds ~= nil then
+		date = time.Unix(seconds, 0):Format("2006-01-02")
+	end
+	local summary = output:match("\nsummary ([^\n]*)") or ""
+	micro.InfoBar():Message("Line " .. line .. ": " .. hash:sub(1, 8) .. " " .. author .. " " .. date .. " " .. summary)
+end